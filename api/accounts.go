@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/mattn/go-mastodon"
+)
+
+type AccountStatusesOptions struct {
+	ExcludeReplies bool
+	OnlyMedia      bool
+}
+
+// Returns the statuses of an account like GetAccountStatuses, narrowed down
+// by opts
+func (c *Client) GetAccountStatusesFiltered(ctx context.Context, id mastodon.ID, opts AccountStatusesOptions, pg *mastodon.Pagination) ([]*mastodon.Status, error) {
+	params := url.Values{}
+	if opts.ExcludeReplies {
+		params.Set("exclude_replies", "true")
+	}
+	if opts.OnlyMedia {
+		params.Set("only_media", "true")
+	}
+	if pg != nil {
+		if pg.MaxID != "" {
+			params.Set("max_id", string(pg.MaxID))
+		}
+		if pg.SinceID != "" {
+			params.Set("since_id", string(pg.SinceID))
+		}
+		if pg.MinID != "" {
+			params.Set("min_id", string(pg.MinID))
+		}
+		if pg.Limit > 0 {
+			params.Set("limit", strconv.FormatInt(pg.Limit, 10))
+		}
+	}
+
+	var statuses []*mastodon.Status
+	err := c.doAPI(ctx, http.MethodGet, accountPath(id, "statuses"), params, &statuses)
+	if err != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
+
+type FeaturedTag struct {
+	ID   mastodon.ID `json:"id"`
+	Name string      `json:"name"`
+	URL  string      `json:"url"`
+	// Some servers send the count as a string
+	StatusesCount json.Number `json:"statuses_count"`
+}
+
+func (c *Client) GetFeaturedTags(ctx context.Context, id mastodon.ID) ([]*FeaturedTag, error) {
+	var tags []*FeaturedTag
+	err := c.doAPI(ctx, http.MethodGet, accountPath(id, "featured_tags"), nil, &tags)
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// FamiliarFollowers are the accounts we follow that also follow the account ID
+type FamiliarFollowers struct {
+	ID       mastodon.ID         `json:"id"`
+	Accounts []*mastodon.Account `json:"accounts"`
+}
+
+func (c *Client) GetFamiliarFollowers(ctx context.Context, ids []mastodon.ID) ([]*FamiliarFollowers, error) {
+	params := url.Values{}
+	for _, id := range ids {
+		params.Add("id[]", string(id))
+	}
+
+	var followers []*FamiliarFollowers
+	err := c.doAPI(ctx, http.MethodGet, "api/v1/accounts/familiar_followers", params, &followers)
+	if err != nil {
+		return nil, err
+	}
+
+	return followers, nil
+}
+
+// Searches accounts by name or address. following restricts the results to
+// accounts we follow
+func (c *Client) SearchAccounts(ctx context.Context, query string, following bool, limit int) ([]*mastodon.Account, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("following", strconv.FormatBool(following))
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+
+	var accounts []*mastodon.Account
+	err := c.doAPI(ctx, http.MethodGet, "api/v1/accounts/search", params, &accounts)
+	if err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/mattn/go-mastodon"
 )
@@ -18,35 +20,59 @@ func NewClient(client *mastodon.Client) *Client {
 	return &Client{Client: client}
 }
 
-type UnreadCount struct {
-	Count int `json:"count"`
-}
-
-func (c *Client) GetNotificationsUnreadCount(ctx context.Context) (int, error) {
+// Performs an authenticated request against an endpoint that go-mastodon
+// does not cover. params are sent as the query string for GET and DELETE
+// requests, and as a form body otherwise. The response is decoded into res
+// when it is not nil
+func (c *Client) doAPI(ctx context.Context, method string, path string, params url.Values, res any) error {
 	u, err := url.Parse(c.Config.Server)
 	if err != nil {
-		return 0, err
+		return err
+	}
+	u = u.JoinPath(path)
+
+	var body io.Reader
+	if method == http.MethodGet || method == http.MethodDelete {
+		if params != nil {
+			u.RawQuery = params.Encode()
+		}
+	} else if params != nil {
+		body = strings.NewReader(params.Encode())
 	}
-	u = u.JoinPath("api/v1/notifications/unread_count")
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
 	if err != nil {
-		return 0, err
+		return err
 	}
 	req.Header.Set("Authorization", "Bearer "+c.Config.AccessToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
 
 	resp, err := c.Do(req)
 	if err != nil {
-		return 0, err
+		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	if res == nil {
+		return nil
 	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
+
+type UnreadCount struct {
+	Count int `json:"count"`
+}
 
+func (c *Client) GetNotificationsUnreadCount(ctx context.Context) (int, error) {
 	var result UnreadCount
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	err := c.doAPI(ctx, http.MethodGet, "api/v1/notifications/unread_count", nil, &result)
+	if err != nil {
 		return 0, err
 	}
 
@@ -0,0 +1,18 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func (c *Client) GetCustomEmojis(ctx context.Context) ([]*mastodon.Emoji, error) {
+	var emojis []*mastodon.Emoji
+	err := c.doAPI(ctx, http.MethodGet, "api/v1/custom_emojis", nil, &emojis)
+	if err != nil {
+		return nil, err
+	}
+
+	return emojis, nil
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// Actions of a filter on the statuses it matches
+const (
+	FilterActionWarn = "warn"
+	FilterActionHide = "hide"
+)
+
+// Contexts a filter applies in
+const (
+	FilterContextHome          = "home"
+	FilterContextNotifications = "notifications"
+	FilterContextPublic        = "public"
+	FilterContextThread        = "thread"
+	FilterContextAccount       = "account"
+)
+
+var FilterContexts = []string{
+	FilterContextHome,
+	FilterContextNotifications,
+	FilterContextPublic,
+	FilterContextThread,
+	FilterContextAccount,
+}
+
+type FilterKeyword struct {
+	ID        mastodon.ID `json:"id"`
+	Keyword   string      `json:"keyword"`
+	WholeWord bool        `json:"whole_word"`
+}
+
+// Filter is a filter of the v2 API, which groups keywords under a title
+type Filter struct {
+	ID           mastodon.ID      `json:"id"`
+	Title        string           `json:"title"`
+	Context      []string         `json:"context"`
+	ExpiresAt    *time.Time       `json:"expires_at"`
+	FilterAction string           `json:"filter_action"`
+	Keywords     []*FilterKeyword `json:"keywords"`
+}
+
+// Reports whether the filter has expired at the given time
+func (f *Filter) Expired(now time.Time) bool {
+	return f.ExpiresAt != nil && !f.ExpiresAt.IsZero() && f.ExpiresAt.Before(now)
+}
+
+func filterPath(id mastodon.ID, action string) string {
+	path := fmt.Sprintf("api/v2/filters/%s", url.PathEscape(string(id)))
+	if action != "" {
+		path += "/" + action
+	}
+	return path
+}
+
+func keywordPath(id mastodon.ID) string {
+	return fmt.Sprintf("api/v2/filters/keywords/%s", url.PathEscape(string(id)))
+}
+
+// Returns the form of the settings of a filter. The filter expires after
+// expiresIn, or never when it is zero
+func filterParams(filter *Filter, expiresIn time.Duration) url.Values {
+	params := url.Values{}
+	params.Set("title", filter.Title)
+	for _, context := range filter.Context {
+		params.Add("context[]", context)
+	}
+	if filter.FilterAction != "" {
+		params.Set("filter_action", filter.FilterAction)
+	}
+	if expiresIn > 0 {
+		params.Set("expires_in", strconv.Itoa(int(expiresIn.Seconds())))
+	} else {
+		params.Set("expires_in", "")
+	}
+	return params
+}
+
+func (c *Client) GetFilters(ctx context.Context) ([]*Filter, error) {
+	var filters []*Filter
+	err := c.doAPI(ctx, http.MethodGet, "api/v2/filters", nil, &filters)
+	if err != nil {
+		return nil, err
+	}
+
+	return filters, nil
+}
+
+// Creates a filter with the settings of filter, without keywords
+func (c *Client) CreateFilter(ctx context.Context, filter *Filter, expiresIn time.Duration) (*Filter, error) {
+	var created Filter
+	err := c.doAPI(ctx, http.MethodPost, "api/v2/filters", filterParams(filter, expiresIn), &created)
+	if err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// Saves the settings of filter. Its keywords are changed separately
+func (c *Client) UpdateFilter(ctx context.Context, filter *Filter, expiresIn time.Duration) (*Filter, error) {
+	var updated Filter
+	err := c.doAPI(ctx, http.MethodPut, filterPath(filter.ID, ""), filterParams(filter, expiresIn), &updated)
+	if err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+func (c *Client) DeleteFilter(ctx context.Context, id mastodon.ID) error {
+	return c.doAPI(ctx, http.MethodDelete, filterPath(id, ""), nil, nil)
+}
+
+func (c *Client) AddFilterKeyword(ctx context.Context, filterID mastodon.ID, keyword string, wholeWord bool) (*FilterKeyword, error) {
+	params := url.Values{}
+	params.Set("keyword", keyword)
+	params.Set("whole_word", strconv.FormatBool(wholeWord))
+
+	var created FilterKeyword
+	err := c.doAPI(ctx, http.MethodPost, filterPath(filterID, "keywords"), params, &created)
+	if err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+func (c *Client) UpdateFilterKeyword(ctx context.Context, keyword *FilterKeyword) (*FilterKeyword, error) {
+	params := url.Values{}
+	params.Set("keyword", keyword.Keyword)
+	params.Set("whole_word", strconv.FormatBool(keyword.WholeWord))
+
+	var updated FilterKeyword
+	err := c.doAPI(ctx, http.MethodPut, keywordPath(keyword.ID), params, &updated)
+	if err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+func (c *Client) DeleteFilterKeyword(ctx context.Context, id mastodon.ID) error {
+	return c.doAPI(ctx, http.MethodDelete, keywordPath(id), nil, nil)
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// Replies shown in a list timeline, besides those to the author themselves
+const (
+	RepliesPolicyFollowed = "followed"
+	RepliesPolicyList     = "list"
+	RepliesPolicyNone     = "none"
+)
+
+// List extends mastodon.List with the fields go-mastodon does not decode
+type List struct {
+	ID            mastodon.ID `json:"id"`
+	Title         string      `json:"title"`
+	RepliesPolicy string      `json:"replies_policy"`
+	// Exclusive lists hide the statuses of their members from home
+	Exclusive bool `json:"exclusive"`
+}
+
+func listPath(id mastodon.ID, action string) string {
+	path := fmt.Sprintf("api/v1/lists/%s", url.PathEscape(string(id)))
+	if action != "" {
+		path += "/" + action
+	}
+	return path
+}
+
+func listParams(list *List) url.Values {
+	params := url.Values{}
+	params.Set("title", list.Title)
+	if list.RepliesPolicy != "" {
+		params.Set("replies_policy", list.RepliesPolicy)
+	}
+	params.Set("exclusive", strconv.FormatBool(list.Exclusive))
+	return params
+}
+
+func (c *Client) GetLists(ctx context.Context) ([]*List, error) {
+	var lists []*List
+	err := c.doAPI(ctx, http.MethodGet, "api/v1/lists", nil, &lists)
+	if err != nil {
+		return nil, err
+	}
+
+	return lists, nil
+}
+
+// Creates a list with the title, replies policy and exclusive setting of list
+func (c *Client) CreateList(ctx context.Context, list *List) (*List, error) {
+	var created List
+	err := c.doAPI(ctx, http.MethodPost, "api/v1/lists", listParams(list), &created)
+	if err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// Saves the title, replies policy and exclusive setting of list
+func (c *Client) UpdateList(ctx context.Context, list *List) (*List, error) {
+	var updated List
+	err := c.doAPI(ctx, http.MethodPut, listPath(list.ID, ""), listParams(list), &updated)
+	if err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+func (c *Client) DeleteList(ctx context.Context, id mastodon.ID) error {
+	return c.doAPI(ctx, http.MethodDelete, listPath(id, ""), nil, nil)
+}
+
+// Returns every member of a list
+func (c *Client) GetListMembers(ctx context.Context, id mastodon.ID) ([]*mastodon.Account, error) {
+	params := url.Values{}
+	params.Set("limit", "0")
+
+	var accounts []*mastodon.Account
+	err := c.doAPI(ctx, http.MethodGet, listPath(id, "accounts"), params, &accounts)
+	if err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+// Adds accounts to a list. Only accounts we follow can be added
+func (c *Client) AddListMembers(ctx context.Context, id mastodon.ID, accountIDs ...mastodon.ID) error {
+	return c.doAPI(ctx, http.MethodPost, listPath(id, "accounts"), accountIDParams(accountIDs), nil)
+}
+
+func (c *Client) RemoveListMembers(ctx context.Context, id mastodon.ID, accountIDs ...mastodon.ID) error {
+	return c.doAPI(ctx, http.MethodDelete, listPath(id, "accounts"), accountIDParams(accountIDs), nil)
+}
+
+func accountIDParams(ids []mastodon.ID) url.Values {
+	params := url.Values{}
+	for _, id := range ids {
+		params.Add("account_ids[]", string(id))
+	}
+	return params
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"context"
+	"net/http"
+)
+
+type Preferences struct {
+	PostingDefaultVisibility string `json:"posting:default:visibility"`
+	PostingDefaultSensitive  bool   `json:"posting:default:sensitive"`
+	PostingDefaultLanguage   string `json:"posting:default:language"`
+	ReadingExpandMedia       string `json:"reading:expand:media"`
+	ReadingExpandSpoilers    bool   `json:"reading:expand:spoilers"`
+}
+
+func (c *Client) GetPreferences(ctx context.Context) (*Preferences, error) {
+	var prefs Preferences
+	err := c.doAPI(ctx, http.MethodGet, "api/v1/preferences", nil, &prefs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &prefs, nil
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// Relationship extends mastodon.Relationship with the fields go-mastodon
+// does not decode
+type Relationship struct {
+	ID                  mastodon.ID `json:"id"`
+	Following           bool        `json:"following"`
+	ShowingReblogs      bool        `json:"showing_reblogs"`
+	Notifying           bool        `json:"notifying"`
+	FollowedBy          bool        `json:"followed_by"`
+	Blocking            bool        `json:"blocking"`
+	BlockedBy           bool        `json:"blocked_by"`
+	Muting              bool        `json:"muting"`
+	MutingNotifications bool        `json:"muting_notifications"`
+	Requested           bool        `json:"requested"`
+	RequestedBy         bool        `json:"requested_by"`
+	DomainBlocking      bool        `json:"domain_blocking"`
+	Endorsed            bool        `json:"endorsed"`
+	Note                string      `json:"note"`
+}
+
+func accountPath(id mastodon.ID, action string) string {
+	return fmt.Sprintf("api/v1/accounts/%s/%s", url.PathEscape(string(id)), action)
+}
+
+func (c *Client) GetRelationships(ctx context.Context, ids []mastodon.ID) ([]*Relationship, error) {
+	params := url.Values{}
+	for _, id := range ids {
+		params.Add("id[]", string(id))
+	}
+
+	var relationships []*Relationship
+	err := c.doAPI(ctx, http.MethodGet, "api/v1/accounts/relationships", params, &relationships)
+	if err != nil {
+		return nil, err
+	}
+
+	return relationships, nil
+}
+
+// Follows an account, or updates the options of an account already followed.
+// reblogs shows their boosts in the home timeline and notify sends a
+// notification when they post
+func (c *Client) FollowAccount(ctx context.Context, id mastodon.ID, reblogs, notify bool) (*Relationship, error) {
+	params := url.Values{}
+	params.Set("reblogs", strconv.FormatBool(reblogs))
+	params.Set("notify", strconv.FormatBool(notify))
+
+	var relationship Relationship
+	err := c.doAPI(ctx, http.MethodPost, accountPath(id, "follow"), params, &relationship)
+	if err != nil {
+		return nil, err
+	}
+
+	return &relationship, nil
+}
+
+func (c *Client) UnfollowAccount(ctx context.Context, id mastodon.ID) (*Relationship, error) {
+	return c.relationshipAction(ctx, id, "unfollow", nil)
+}
+
+// Mutes an account for the given duration, or indefinitely when it is zero.
+// notifications also hides the notifications from the account
+func (c *Client) MuteAccount(ctx context.Context, id mastodon.ID, notifications bool, duration time.Duration) (*Relationship, error) {
+	params := url.Values{}
+	params.Set("notifications", strconv.FormatBool(notifications))
+	params.Set("duration", strconv.Itoa(int(duration.Seconds())))
+	return c.relationshipAction(ctx, id, "mute", params)
+}
+
+func (c *Client) UnmuteAccount(ctx context.Context, id mastodon.ID) (*Relationship, error) {
+	return c.relationshipAction(ctx, id, "unmute", nil)
+}
+
+func (c *Client) BlockAccount(ctx context.Context, id mastodon.ID) (*Relationship, error) {
+	return c.relationshipAction(ctx, id, "block", nil)
+}
+
+func (c *Client) UnblockAccount(ctx context.Context, id mastodon.ID) (*Relationship, error) {
+	return c.relationshipAction(ctx, id, "unblock", nil)
+}
+
+// Sets the private note on an account, or removes it when comment is empty
+func (c *Client) SetAccountNote(ctx context.Context, id mastodon.ID, comment string) (*Relationship, error) {
+	params := url.Values{}
+	params.Set("comment", comment)
+	return c.relationshipAction(ctx, id, "note", params)
+}
+
+func (c *Client) relationshipAction(ctx context.Context, id mastodon.ID, action string, params url.Values) (*Relationship, error) {
+	var relationship Relationship
+	err := c.doAPI(ctx, http.MethodPost, accountPath(id, action), params, &relationship)
+	if err != nil {
+		return nil, err
+	}
+
+	return &relationship, nil
+}
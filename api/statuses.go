@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// MediaAttribute changes an attachment of a status being edited
+type MediaAttribute struct {
+	ID          mastodon.ID
+	Description string
+	// Focus is the focal point as "x,y", each from -1.0 to 1.0
+	Focus string
+}
+
+// StatusEdit is the new version of an edited status. Media are replaced, so
+// those kept are sent again. Poll replaces the poll, restarting its votes and
+// time, and is left nil to keep it as it is
+type StatusEdit struct {
+	Status          string
+	SpoilerText     string
+	Sensitive       bool
+	Language        string
+	MediaIDs        []mastodon.ID
+	MediaAttributes []MediaAttribute
+	Poll            *mastodon.TootPoll
+}
+
+func statusPath(id mastodon.ID, action string) string {
+	path := fmt.Sprintf("api/v1/statuses/%s", url.PathEscape(string(id)))
+	if action != "" {
+		path += "/" + action
+	}
+	return path
+}
+
+// Saves a new version of a status. Unlike go-mastodon's UpdateStatus, it can
+// change the descriptions and focal points of the attachments
+func (c *Client) EditStatus(ctx context.Context, id mastodon.ID, edit *StatusEdit) (*mastodon.Status, error) {
+	params := url.Values{}
+	params.Set("status", edit.Status)
+	params.Set("spoiler_text", edit.SpoilerText)
+	params.Set("sensitive", strconv.FormatBool(edit.Sensitive))
+	if edit.Language != "" {
+		params.Set("language", edit.Language)
+	}
+	for _, id := range edit.MediaIDs {
+		params.Add("media_ids[]", string(id))
+	}
+	// Indexed, as the form is encoded with its keys sorted
+	for i, attr := range edit.MediaAttributes {
+		key := fmt.Sprintf("media_attributes[%d]", i)
+		params.Set(key+"[id]", string(attr.ID))
+		params.Set(key+"[description]", attr.Description)
+		params.Set(key+"[focus]", attr.Focus)
+	}
+	if poll := edit.Poll; poll != nil {
+		for _, option := range poll.Options {
+			params.Add("poll[options][]", option)
+		}
+		params.Set("poll[expires_in]", strconv.FormatInt(poll.ExpiresInSeconds, 10))
+		params.Set("poll[multiple]", strconv.FormatBool(poll.Multiple))
+		params.Set("poll[hide_totals]", strconv.FormatBool(poll.HideTotals))
+	}
+
+	var status mastodon.Status
+	err := c.doAPI(ctx, http.MethodPut, statusPath(id, ""), params, &status)
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+func mediaPath(id mastodon.ID) string {
+	return fmt.Sprintf("api/v1/media/%s", url.PathEscape(string(id)))
+}
+
+func (c *Client) statusAction(ctx context.Context, id mastodon.ID, action string) (*mastodon.Status, error) {
+	var status mastodon.Status
+	err := c.doAPI(ctx, http.MethodPost, statusPath(id, action), nil, &status)
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// Features one of our statuses on our profile
+func (c *Client) PinStatus(ctx context.Context, id mastodon.ID) (*mastodon.Status, error) {
+	return c.statusAction(ctx, id, "pin")
+}
+
+func (c *Client) UnpinStatus(ctx context.Context, id mastodon.ID) (*mastodon.Status, error) {
+	return c.statusAction(ctx, id, "unpin")
+}
+
+// Stops notifications about the conversation the status is part of
+func (c *Client) MuteConversation(ctx context.Context, id mastodon.ID) (*mastodon.Status, error) {
+	return c.statusAction(ctx, id, "mute")
+}
+
+func (c *Client) UnmuteConversation(ctx context.Context, id mastodon.ID) (*mastodon.Status, error) {
+	return c.statusAction(ctx, id, "unmute")
+}
+
+// Returns an attachment that was uploaded. Its URL is empty while the server
+// is still processing it
+func (c *Client) GetMedia(ctx context.Context, id mastodon.ID) (*mastodon.Attachment, error) {
+	var attachment mastodon.Attachment
+	err := c.doAPI(ctx, http.MethodGet, mediaPath(id), nil, &attachment)
+	if err != nil {
+		return nil, err
+	}
+
+	return &attachment, nil
+}
+
+// Changes the description and focal point of an attachment not yet posted
+func (c *Client) UpdateMedia(ctx context.Context, attr *MediaAttribute) (*mastodon.Attachment, error) {
+	params := url.Values{}
+	params.Set("description", attr.Description)
+	if attr.Focus != "" {
+		params.Set("focus", attr.Focus)
+	}
+
+	var attachment mastodon.Attachment
+	err := c.doAPI(ctx, http.MethodPut, mediaPath(attr.ID), params, &attachment)
+	if err != nil {
+		return nil, err
+	}
+
+	return &attachment, nil
+}
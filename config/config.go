@@ -18,10 +18,121 @@ type ConfigAuth struct {
 	AccessToken  string `json:"access_token"`
 }
 
+type ConfigTimeline struct {
+	// RowLayout is either "oneline" or "multiline"
+	RowLayout string `json:"row_layout"`
+}
+
+type ConfigReading struct {
+	// ExpandSpoilers shows the content behind content warnings by default
+	ExpandSpoilers bool `json:"expand_spoilers"`
+	// ExpandMedia is one of "default", "show_all" or "hide_all"
+	ExpandMedia string `json:"expand_media"`
+	// SyncServer replaces the options above with the server preferences
+	SyncServer bool `json:"sync_server"`
+}
+
+type ConfigImages struct {
+	// RawCacheMB limits the memory used by decoded images
+	RawCacheMB int64 `json:"raw_cache_mb"`
+	// ScaledCacheMB limits the memory used by images scaled for the terminal
+	ScaledCacheMB int64 `json:"scaled_cache_mb"`
+	// DiskCacheMB limits the size of the downloaded images kept between runs
+	DiskCacheMB int64 `json:"disk_cache_mb"`
+	// DisableDiskCache stops keeping downloaded images between runs
+	DisableDiskCache bool `json:"disable_disk_cache"`
+	// ReducedMotion shows the first frame of animated images instead of playing them
+	ReducedMotion bool `json:"reduced_motion"`
+	// Protocol is one of "auto", "kitty", "sixel", "halfblock" or "none"
+	Protocol string `json:"protocol"`
+}
+
+// ConfigHandler is an external program that opens media. It applies when one
+// of Types matches and Pattern matches the URL, an empty field matches anything
+type ConfigHandler struct {
+	// Types are attachment types such as "video" or "gifv", or MIME types
+	// such as "image/png" or "audio/*"
+	Types []string `json:"types"`
+	// Pattern is a regular expression matched against the URL
+	Pattern string `json:"pattern"`
+	// Command is the program and its arguments, "{url}" is replaced by the URL
+	Command string `json:"command"`
+	// Suspend hands the terminal to the command until it exits, for
+	// programs that run in the terminal
+	Suspend bool `json:"suspend"`
+}
+
+type ConfigMedia struct {
+	// DownloadDir is where the media viewer saves files
+	DownloadDir string `json:"download_dir"`
+	// Handlers are tried in order, falling back to the system opener
+	Handlers []ConfigHandler `json:"handlers"`
+}
+
+// ConfigMuteRule hides the statuses that match every field set on it. A rule
+// without any field set is ignored
+type ConfigMuteRule struct {
+	// Content is a regular expression matched against the text and content
+	// warning of the status
+	Content string `json:"content"`
+	// Language is a language code such as "de"
+	Language string `json:"language"`
+	// Domain is the server of the author, subdomains included
+	Domain string `json:"domain"`
+	// BoostsFrom is an account such as "user@example.com" whose boosts are
+	// hidden, while its own statuses are still shown
+	BoostsFrom string `json:"boosts_from"`
+}
+
+type ConfigMute struct {
+	// Rules hide the statuses they match in the home, list and account
+	// timelines. Threads show every status
+	Rules []ConfigMuteRule `json:"rules"`
+	// HideRepliesToUnfollowed hides replies to accounts we do not follow
+	HideRepliesToUnfollowed bool `json:"hide_replies_to_unfollowed"`
+}
+
+type ConfigCompose struct {
+	// AltText is one of "require", "warn" or "off", deciding what happens
+	// when attachments without a description are posted
+	AltText string `json:"alt_text"`
+}
+
 type Config struct {
-	Auth ConfigAuth `json:"auth"`
+	Auth     ConfigAuth     `json:"auth"`
+	Timeline ConfigTimeline `json:"timeline"`
+	Reading  ConfigReading  `json:"reading"`
+	Images   ConfigImages   `json:"images"`
+	Media    ConfigMedia    `json:"media"`
+	Mute     ConfigMute     `json:"mute"`
+	Compose  ConfigCompose  `json:"compose"`
 }
 
+const (
+	RowLayoutOneLine   = "oneline"
+	RowLayoutMultiLine = "multiline"
+)
+
+const (
+	ExpandMediaDefault = "default"
+	ExpandMediaShowAll = "show_all"
+	ExpandMediaHideAll = "hide_all"
+)
+
+const (
+	AltTextRequire = "require"
+	AltTextWarn    = "warn"
+	AltTextOff     = "off"
+)
+
+const (
+	ImageProtocolAuto      = "auto"
+	ImageProtocolKitty     = "kitty"
+	ImageProtocolSixel     = "sixel"
+	ImageProtocolHalfBlock = "halfblock"
+	ImageProtocolNone      = "none"
+)
+
 var configDirName = strings.ToLower(constants.AppName)
 var configFileName = "config.json"
 
@@ -44,6 +155,28 @@ func GetConfigDir() string {
 	return filepath.Join(".", configDirName)
 }
 
+func GetCacheDir() string {
+	if cacheDir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(cacheDir, configDirName)
+	}
+
+	// Cannot determine cache directory, using the config directory
+	return filepath.Join(GetConfigDir(), "cache")
+}
+
+func GetDownloadDir() string {
+	if xdgDownloadDir := os.Getenv("XDG_DOWNLOAD_DIR"); xdgDownloadDir != "" {
+		return xdgDownloadDir
+	}
+
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(homeDir, "Downloads")
+	}
+
+	// Cannot determine download directory, using current directory
+	return "."
+}
+
 func GetConfigFile() string {
 	configDir := GetConfigDir()
 	return filepath.Join(configDir, configFileName)
@@ -62,5 +195,25 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("error unmarshalling JSON from %s: %w", configFile, err)
 	}
 
+	if config.Timeline.RowLayout == "" {
+		config.Timeline.RowLayout = RowLayoutOneLine
+	}
+	if config.Reading.ExpandMedia == "" {
+		config.Reading.ExpandMedia = ExpandMediaDefault
+	}
+	if config.Compose.AltText == "" {
+		config.Compose.AltText = AltTextWarn
+	}
+	if config.Images.Protocol == "" {
+		config.Images.Protocol = ImageProtocolAuto
+	}
+	if config.Media.DownloadDir == "" {
+		config.Media.DownloadDir = GetDownloadDir()
+	} else if rest, ok := strings.CutPrefix(config.Media.DownloadDir, "~/"); ok {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			config.Media.DownloadDir = filepath.Join(homeDir, rest)
+		}
+	}
+
 	return &config, nil
 }
@@ -6,7 +6,9 @@ require (
 	git.sr.ht/~rockorager/vaxis v0.15.0
 	github.com/k3a/html2text v1.2.1
 	github.com/mattn/go-mastodon v0.0.10
+	github.com/rivo/uniseg v0.4.7
 	golang.org/x/image v0.33.0
+	golang.org/x/net v0.47.0
 	golang.org/x/text v0.31.0
 )
 
@@ -17,8 +19,8 @@ require (
 	github.com/gorilla/websocket v1.5.3 // indirect
 	github.com/mattn/go-runewidth v0.0.19 // indirect
 	github.com/mattn/go-sixel v0.0.5 // indirect
-	github.com/rivo/uniseg v0.4.7 // indirect
 	github.com/soniakeys/quant v1.0.0 // indirect
 	github.com/tomnomnom/linkheader v0.0.0-20250811210735-e5fe3b51442e // indirect
+	golang.org/x/exp v0.0.0-20230522175609-2e198f4a06a1 // indirect
 	golang.org/x/sys v0.38.0 // indirect
 )
@@ -1,54 +1,265 @@
 package tui
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"strings"
+	"time"
 
 	"git.sr.ht/~rockorager/vaxis"
+	"github.com/AbeEstrada/tuit/api"
 	"github.com/AbeEstrada/tuit/utils"
 	"github.com/mattn/go-mastodon"
 )
 
+// Marks accounts that have a private note
+const noteIndicator = "✎"
+
+type muteDuration struct {
+	label    string
+	duration time.Duration
+}
+
+var muteDurations = []muteDuration{
+	{"Indefinitely", 0},
+	{"5 minutes", 5 * time.Minute},
+	{"30 minutes", 30 * time.Minute},
+	{"1 hour", time.Hour},
+	{"6 hours", 6 * time.Hour},
+	{"1 day", 24 * time.Hour},
+	{"3 days", 3 * 24 * time.Hour},
+	{"7 days", 7 * 24 * time.Hour},
+}
+
+// accountDetails are loaded separately from the account itself
+type accountDetails struct {
+	pinned       []*mastodon.Status
+	featuredTags []*api.FeaturedTag
+	familiar     []*mastodon.Account
+}
+
 type AccountView struct {
 	app *App
+	// Details of the accounts shown so far. A nil entry is still loading
+	accounts     map[mastodon.ID]*accountDetails
+	account      *mastodon.Account
+	menu         *MenuView
+	showingMenu  bool
+	noteEditor   *TextArea
+	editingNote  bool
+	onMenuSelect func(index int)
+	onSelectTab  func(tab AccountTab)
+	onOpenList   func(account *mastodon.Account, list AccountList)
+	scrollOffset int
+	totalHeight  int
+	viewHeight   int
 }
 
 func CreateAccountView() *AccountView {
-	return &AccountView{}
+	return &AccountView{
+		accounts:   make(map[mastodon.ID]*accountDetails),
+		menu:       CreateMenuView(),
+		noteEditor: CreateTextArea(),
+	}
 }
 
 func (v *AccountView) SetApp(app *App) {
 	v.app = app
 }
 
-func (v *AccountView) Draw(win vaxis.Window, focused bool, account *mastodon.Account) {
+// Shows another account, loading its relationship and details the first time
+func (v *AccountView) Select(account *mastodon.Account) {
+	if account == nil || (v.account != nil && v.account.ID == account.ID) {
+		return
+	}
+	v.account = account
+	v.showingMenu = false
+	v.editingNote = false
+	v.scrollOffset = 0
+
+	v.app.RequestRelationships([]mastodon.ID{account.ID})
+	if _, ok := v.accounts[account.ID]; !ok {
+		v.accounts[account.ID] = nil
+		go v.fetchDetails(account.ID)
+	}
+}
+
+// Loads the pinned statuses, featured hashtags and familiar followers of the
+// account. Each part is optional, failures only leave it out
+func (v *AccountView) fetchDetails(id mastodon.ID) {
+	ctx := context.Background()
+	details := &accountDetails{}
+
+	pinned, err := v.app.client.GetAccountPinnedStatuses(ctx, id)
+	if err != nil {
+		log.Printf("Failed to fetch pinned statuses: %v", err)
+	}
+	details.pinned = pinned
+
+	tags, err := v.app.customClient.GetFeaturedTags(ctx, id)
+	if err != nil {
+		log.Printf("Failed to fetch featured tags: %v", err)
+	}
+	details.featuredTags = tags
+
+	familiar, err := v.app.customClient.GetFamiliarFollowers(ctx, []mastodon.ID{id})
+	if err != nil {
+		log.Printf("Failed to fetch familiar followers: %v", err)
+	}
+	for _, followers := range familiar {
+		if followers.ID == id {
+			details.familiar = followers.Accounts
+		}
+	}
+
+	v.app.RunOnMain(func() {
+		v.accounts[id] = details
+	})
+}
+
+// Runs an action that changes the relationship with the account and stores
+// the relationship it returns
+func (v *AccountView) updateRelationship(action string, fn func(ctx context.Context) (*api.Relationship, error)) {
+	relationship, err := fn(context.Background())
+	if err != nil {
+		log.Printf("Failed to %s: %v", action, err)
+		v.app.footer.SetText("Failed to " + action)
+	} else {
+		v.app.SetRelationships(relationship)
+		v.app.footer.SetText("")
+	}
+	v.app.vx.PostEvent(vaxis.Redraw{})
+}
+
+// Returns the states of a relationship as styled labels
+func relationshipSegments(relationship *api.Relationship) []vaxis.Segment {
+	var segments []vaxis.Segment
+	add := func(text string, color vaxis.Color) {
+		if len(segments) > 0 {
+			segments = append(segments, vaxis.Segment{Text: " · "})
+		}
+		segments = append(segments, vaxis.Segment{
+			Text:  text,
+			Style: vaxis.Style{Foreground: color},
+		})
+	}
+
+	if relationship.FollowedBy {
+		add("Follows you", vaxis.IndexColor(6))
+	}
+	if relationship.Following {
+		label := "Following"
+		if !relationship.ShowingReblogs {
+			label += ", boosts hidden"
+		}
+		if relationship.Notifying {
+			label += ", notifying"
+		}
+		add(label, vaxis.IndexColor(2))
+	}
+	if relationship.Requested {
+		add("Requested", vaxis.IndexColor(3))
+	}
+	if relationship.Endorsed {
+		add("Featured", vaxis.IndexColor(4))
+	}
+	if relationship.Muting {
+		add("Muted", vaxis.IndexColor(1))
+	}
+	if relationship.Blocking {
+		add("Blocked", vaxis.IndexColor(1))
+	}
+	if relationship.DomainBlocking {
+		add("Domain blocked", vaxis.IndexColor(1))
+	}
+	return segments
+}
+
+// Returns the most relevant state of a relationship as a short label, for
+// lists of accounts
+func relationshipLabel(relationship *api.Relationship) (string, vaxis.Color) {
+	switch {
+	case relationship == nil:
+		return "", vaxis.ColorDefault
+	case relationship.Blocking:
+		return "Blocked", vaxis.IndexColor(1)
+	case relationship.Muting:
+		return "Muted", vaxis.IndexColor(1)
+	case relationship.Following && relationship.FollowedBy:
+		return "Mutual", vaxis.IndexColor(2)
+	case relationship.Following:
+		return "Following", vaxis.IndexColor(2)
+	case relationship.Requested:
+		return "Requested", vaxis.IndexColor(3)
+	case relationship.FollowedBy:
+		return "Follows you", vaxis.IndexColor(6)
+	}
+	return "", vaxis.ColorDefault
+}
+
+func (v *AccountView) Draw(win vaxis.Window, focused bool, account *mastodon.Account, tab AccountTab) {
 	if account == nil {
+		v.totalHeight = 0
 		win.Println(0, vaxis.Segment{Text: ""})
 		return
 	}
 
+	if v.showingMenu {
+		v.menu.Draw(win, focused)
+		return
+	}
+	if v.editingNote {
+		v.drawNoteEditor(win, focused, account)
+		return
+	}
+
 	width, height := win.Size()
+	height = height - 1
+	win = win.New(0, 0, width, height)
+	v.viewHeight = height
+	so := v.scrollOffset
 	y := 0
 
+	if account.HeaderStatic != "" && !strings.HasSuffix(account.HeaderStatic, "/missing.png") {
+		// Banners are usually 3:1
+		bannerHeight := reservedMediaHeight(width, 3, 1)
+		banner, cached := utils.ImageCache.Get(account.HeaderStatic, width, bannerHeight)
+		if cached {
+			_, bannerHeight = banner.CellSize()
+			if imageVisible(y-so, bannerHeight, height) {
+				banner.Draw(win.New(0, y-so, width, bannerHeight))
+			}
+		} else {
+			utils.ImageCache.LoadAsync(account.HeaderStatic)
+		}
+		y += bannerHeight + 1
+	}
+
 	avatarWidth := 24
 	avatarHeight := 12
 	avatarURL := account.AvatarStatic
 
 	vxImage, cached := utils.ImageCache.Get(avatarURL, avatarWidth, avatarHeight)
 	if cached {
-		if width > avatarWidth {
-			imgWin := win.New(0, y, avatarWidth, avatarHeight)
+		_, imgHeight := vxImage.CellSize()
+		if width > avatarWidth && imageVisible(y-so, imgHeight, height) {
+			imgWin := win.New(0, y-so, avatarWidth, avatarHeight)
 			vxImage.Draw(imgWin)
 		}
 	} else {
 		utils.ImageCache.LoadAsync(avatarURL)
 	}
 
+	emojis := v.app.Emojis(account.Emojis)
+
 	metaX := avatarWidth + 1
 	metaY := 0
-	metaWin := win.New(metaX, metaY, width-metaX, avatarHeight)
-	metaWin.Println(
+	metaWin := win.New(metaX, y-so, width-metaX, avatarHeight)
+	printText(
+		metaWin,
 		metaY,
+		emojis,
 		vaxis.Segment{
 			Text:  account.DisplayName,
 			Style: vaxis.Style{Attribute: vaxis.AttrBold},
@@ -63,6 +274,12 @@ func (v *AccountView) Draw(win vaxis.Window, focused bool, account *mastodon.Acc
 		},
 	)
 	metaY += 1
+	if relationship := v.app.Relationship(account.ID); relationship != nil {
+		if segments := relationshipSegments(relationship); len(segments) > 0 {
+			metaWin.New(0, metaY, -1, 1).PrintTruncate(0, segments...)
+			metaY += 1
+		}
+	}
 	if account.Bot {
 		metaWin.Println(
 			metaY,
@@ -103,8 +320,7 @@ func (v *AccountView) Draw(win vaxis.Window, focused bool, account *mastodon.Acc
 
 	y += avatarHeight
 
-	fieldsWin := win.New(0, y, width, len(account.Fields))
-	for i, field := range account.Fields {
+	for _, field := range account.Fields {
 		valueSegments := utils.ParseStatus(field.Value, nil)
 
 		var flatText strings.Builder
@@ -132,8 +348,10 @@ func (v *AccountView) Draw(win vaxis.Window, focused bool, account *mastodon.Acc
 			}
 		}
 
-		fieldsWin.Println(
-			i,
+		printText(
+			win,
+			y-so,
+			emojis,
 			vaxis.Segment{Text: verified, Style: verifiedStyle},
 			vaxis.Segment{
 				Text:  fmt.Sprintf("%s: ", field.Name),
@@ -145,13 +363,343 @@ func (v *AccountView) Draw(win vaxis.Window, focused bool, account *mastodon.Acc
 	}
 	y += 1
 
-	contentHeight := height - y
-	contentWin := win.New(0, y, width, contentHeight)
 	content := utils.ParseStatus(account.Note, nil)
-	_, rows := contentWin.Wrap(content...)
-
+	_, rows := wrapText(win.New(0, -height*4, width, height*4), emojis, content...)
+	wrapText(win.New(0, y-so, width, height*4), emojis, content...)
 	y += rows
 
+	headingStyle := vaxis.Style{Attribute: vaxis.AttrBold}
+	dimStyle := vaxis.Style{Attribute: vaxis.AttrDim}
+
+	if relationship := v.app.Relationship(account.ID); relationship != nil && relationship.Note != "" {
+		y += 1
+		win.Println(y-so, vaxis.Segment{Text: noteIndicator + " Note", Style: headingStyle})
+		y += 1
+		note := vaxis.Segment{Text: relationship.Note, Style: vaxis.Style{Attribute: vaxis.AttrItalic}}
+		_, rows := win.New(0, -height*4, width, height*4).Wrap(note)
+		win.New(0, y-so, width, height*4).Wrap(note)
+		y += rows
+	}
+
+	details := v.accounts[account.ID]
+	if details != nil && len(details.familiar) > 0 {
+		names := make([]string, 0, 3)
+		for _, follower := range details.familiar[:min(len(details.familiar), 3)] {
+			names = append(names, "@"+follower.Acct)
+		}
+		text := "Followed by " + strings.Join(names, ", ")
+		if others := len(details.familiar) - len(names); others > 0 {
+			text += fmt.Sprintf(" and %d others you follow", others)
+		}
+		win.New(0, y-so, width, 1).PrintTruncate(0, vaxis.Segment{Text: text, Style: dimStyle})
+		y += 2
+	}
+
+	if details != nil && len(details.featuredTags) > 0 {
+		win.Println(y-so, vaxis.Segment{Text: "Featured hashtags", Style: headingStyle})
+		y += 1
+		for _, tag := range details.featuredTags {
+			win.New(0, y-so, width, 1).PrintTruncate(0,
+				vaxis.Segment{
+					Text:  "#" + tag.Name,
+					Style: vaxis.Style{Foreground: vaxis.IndexColor(4), Hyperlink: tag.URL},
+				},
+				vaxis.Segment{Text: fmt.Sprintf(" · %s posts", tag.StatusesCount), Style: dimStyle},
+			)
+			y += 1
+		}
+		y += 1
+	}
+
+	if details != nil && len(details.pinned) > 0 {
+		win.Println(y-so, vaxis.Segment{Text: "Pinned", Style: headingStyle})
+		y += 1
+		for _, status := range details.pinned {
+			preview := status.SpoilerText
+			if preview == "" {
+				preview = utils.FirstLine(utils.ParseStatus(status.Content, status.Tags))
+			}
+			printText(win.New(0, y-so, width, 1), 0, v.app.Emojis(status.Emojis),
+				vaxis.Segment{Text: status.CreatedAt.Local().Format("Jan 2") + "  ", Style: dimStyle},
+				vaxis.Segment{Text: preview},
+			)
+			y += 1
+		}
+		y += 1
+	}
+
+	tabSegments := make([]vaxis.Segment, 0, len(accountTabs)*2)
+	for i, t := range accountTabs {
+		if i > 0 {
+			tabSegments = append(tabSegments, vaxis.Segment{Text: " · ", Style: dimStyle})
+		}
+		style := dimStyle
+		if t == tab {
+			style = vaxis.Style{Attribute: vaxis.AttrBold | vaxis.AttrReverse}
+		}
+		tabSegments = append(tabSegments, vaxis.Segment{Text: fmt.Sprintf(" %d %s ", i+1, t), Style: style})
+	}
+	win.New(0, y-so, width, 1).PrintTruncate(0, tabSegments...)
+	y += 2
+
+	if focused {
+		win.New(0, y-so, width, 1).PrintTruncate(0, vaxis.Segment{
+			Text:  "f follow · b boosts · n notify · N note · M mute · B block · L lists · F followers · W following · 1-3 tabs",
+			Style: dimStyle,
+		})
+		y += 1
+	}
+
+	v.totalHeight = y
+}
+
+func (v *AccountView) drawNoteEditor(win vaxis.Window, focused bool, account *mastodon.Account) {
+	width, height := win.Size()
+	win.New(0, 0, width, 1).PrintTruncate(0, vaxis.Segment{
+		Text:  "Note on @" + account.Acct,
+		Style: vaxis.Style{Attribute: vaxis.AttrBold},
+	})
+	win.New(0, 1, width, 1).PrintTruncate(0, vaxis.Segment{
+		Text:  "Only visible to you · Ctrl+S save · Ctrl+E $EDITOR · Esc cancel",
+		Style: vaxis.Style{Attribute: vaxis.AttrDim},
+	})
+	v.noteEditor.Draw(win.New(0, 3, width, height-4), focused)
+}
+
+// Reports whether the view handles every key itself, while a menu or the
+// note editor is open
+func (v *AccountView) capturesKeys() bool {
+	return v.showingMenu || v.editingNote
+}
+
+func (v *AccountView) handleNoteKey(key vaxis.Key) {
+	account := v.account
+	switch {
+	case key.Matches('s', vaxis.ModCtrl):
+		v.editingNote = false
+		client := v.app.customClient
+		note := strings.TrimSpace(v.noteEditor.String())
+		go v.updateRelationship("save note", func(ctx context.Context) (*api.Relationship, error) {
+			return client.SetAccountNote(ctx, account.ID, note)
+		})
+	case key.Matches('e', vaxis.ModCtrl):
+		text, err := v.app.EditExternally(v.noteEditor.String(), ".txt")
+		if err != nil {
+			log.Printf("Failed to edit note: %v", err)
+			v.app.footer.SetText("Failed to run the editor")
+			return
+		}
+		v.noteEditor.SetText(strings.TrimRight(text, "\n"))
+	case key.Matches(vaxis.KeyEsc):
+		v.editingNote = false
+	default:
+		v.noteEditor.HandleKey(key)
+	}
 }
 
-func (v *AccountView) HandleKey(key vaxis.Key) {}
+func (v *AccountView) openMenu(title string, items []MenuItem, toggles bool, onSelect func(index int)) {
+	v.menu.SetItems(title, items, toggles)
+	v.onMenuSelect = onSelect
+	v.showingMenu = true
+}
+
+func (v *AccountView) HandleKey(key vaxis.Key) {
+	if v.showingMenu {
+		switch v.menu.HandleKey(key) {
+		case "select":
+			if !v.menu.toggles {
+				v.showingMenu = false
+			}
+			v.onMenuSelect(v.menu.Selected())
+		case "close":
+			v.showingMenu = false
+		}
+		return
+	}
+	if v.editingNote {
+		v.handleNoteKey(key)
+		return
+	}
+
+	account := v.account
+	if account == nil {
+		return
+	}
+
+	switch {
+	case key.Matches('j'):
+		if v.scrollOffset < v.totalHeight-v.viewHeight {
+			v.scrollOffset++
+		}
+		return
+	case key.Matches('k'):
+		if v.scrollOffset > 0 {
+			v.scrollOffset--
+		}
+		return
+	case key.Matches('g'):
+		v.scrollOffset = 0
+		return
+	case key.Matches('G'):
+		v.scrollOffset = max(v.totalHeight-v.viewHeight, 0)
+		return
+	case key.Keycode >= '1' && key.Keycode < '1'+rune(len(accountTabs)) && key.Modifiers == 0:
+		if v.onSelectTab != nil {
+			v.onSelectTab(accountTabs[key.Keycode-'1'])
+		}
+		return
+	case key.Matches('F'):
+		if v.onOpenList != nil {
+			v.onOpenList(account, AccountListFollowers)
+		}
+		return
+	case key.Matches('W'):
+		if v.onOpenList != nil {
+			v.onOpenList(account, AccountListFollowing)
+		}
+		return
+	}
+
+	relationship := v.app.Relationship(account.ID)
+	if relationship == nil {
+		return
+	}
+	client := v.app.customClient
+	id := account.ID
+
+	switch {
+	case key.Matches('f'):
+		if relationship.Following || relationship.Requested {
+			go v.updateRelationship("unfollow", func(ctx context.Context) (*api.Relationship, error) {
+				return client.UnfollowAccount(ctx, id)
+			})
+		} else {
+			go v.updateRelationship("follow", func(ctx context.Context) (*api.Relationship, error) {
+				return client.FollowAccount(ctx, id, true, false)
+			})
+		}
+	case key.Matches('b'):
+		if relationship.Following {
+			go v.updateRelationship("update boosts", func(ctx context.Context) (*api.Relationship, error) {
+				return client.FollowAccount(ctx, id, !relationship.ShowingReblogs, relationship.Notifying)
+			})
+		}
+	case key.Matches('n'):
+		if relationship.Following {
+			go v.updateRelationship("update notifications", func(ctx context.Context) (*api.Relationship, error) {
+				return client.FollowAccount(ctx, id, relationship.ShowingReblogs, !relationship.Notifying)
+			})
+		}
+	case key.Matches('N'):
+		v.noteEditor.SetText(relationship.Note)
+		v.editingNote = true
+	case key.Matches('M'):
+		if relationship.Muting {
+			go v.updateRelationship("unmute", func(ctx context.Context) (*api.Relationship, error) {
+				return client.UnmuteAccount(ctx, id)
+			})
+			return
+		}
+		items := make([]MenuItem, len(muteDurations))
+		for i, d := range muteDurations {
+			items[i] = MenuItem{Label: d.label}
+		}
+		v.openMenu("Mute @"+account.Acct+" for", items, false, func(index int) {
+			duration := muteDurations[index].duration
+			items := []MenuItem{{Label: "Hide notifications too"}, {Label: "Keep notifications"}}
+			v.openMenu("Notifications from @"+account.Acct, items, false, func(index int) {
+				notifications := index == 0
+				go v.updateRelationship("mute", func(ctx context.Context) (*api.Relationship, error) {
+					return client.MuteAccount(ctx, id, notifications, duration)
+				})
+			})
+		})
+	case key.Matches('B'):
+		if relationship.Blocking {
+			go v.updateRelationship("unblock", func(ctx context.Context) (*api.Relationship, error) {
+				return client.UnblockAccount(ctx, id)
+			})
+			return
+		}
+		items := []MenuItem{{Label: "Block"}, {Label: "Cancel"}}
+		v.openMenu("Block @"+account.Acct+"?", items, false, func(index int) {
+			if index != 0 {
+				return
+			}
+			go v.updateRelationship("block", func(ctx context.Context) (*api.Relationship, error) {
+				return client.BlockAccount(ctx, id)
+			})
+		})
+	case key.Matches('L'):
+		if !relationship.Following {
+			v.app.footer.SetText("Only followed accounts can be added to lists")
+			return
+		}
+		go v.openListsMenu(account)
+	}
+}
+
+// Shows every list with the ones that include the account checked. Selecting
+// a list adds the account to it or removes it. The lists load in the
+// background and the menu opens on the main loop, unless another account was
+// selected meanwhile
+func (v *AccountView) openListsMenu(account *mastodon.Account) {
+	ctx := context.Background()
+	fail := func(text string) {
+		v.app.RunOnMain(func() {
+			v.app.footer.SetText(text)
+		})
+	}
+	lists, err := v.app.client.GetLists(ctx)
+	if err != nil {
+		log.Printf("Failed to fetch lists: %v", err)
+		fail("Failed to fetch lists")
+		return
+	}
+	if len(lists) == 0 {
+		fail("There are no lists yet")
+		return
+	}
+	memberOf, err := v.app.client.GetAccountLists(ctx, account.ID)
+	if err != nil {
+		log.Printf("Failed to fetch lists of account: %v", err)
+		fail("Failed to fetch lists")
+		return
+	}
+
+	member := make(map[mastodon.ID]bool, len(memberOf))
+	for _, list := range memberOf {
+		member[list.ID] = true
+	}
+	items := make([]MenuItem, len(lists))
+	for i, list := range lists {
+		items[i] = MenuItem{Label: list.Title, Checked: member[list.ID]}
+	}
+
+	v.app.RunOnMain(func() {
+		if v.account == nil || v.account.ID != account.ID {
+			return
+		}
+		v.openMenu("Lists with @"+account.Acct, items, true, func(index int) {
+			list := lists[index]
+			checked := items[index].Checked
+			go func() {
+				var err error
+				if checked {
+					err = v.app.client.RemoveFromList(context.Background(), list.ID, account.ID)
+				} else {
+					err = v.app.client.AddToList(context.Background(), list.ID, account.ID)
+				}
+				v.app.RunOnMain(func() {
+					if err != nil {
+						log.Printf("Failed to update list %s: %v", list.Title, err)
+						v.app.footer.SetText("Failed to update " + list.Title)
+						return
+					}
+					// The menu shows this slice for as long as it is open
+					items[index].Checked = !checked
+				})
+			}()
+		})
+	})
+}
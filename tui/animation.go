@@ -0,0 +1,136 @@
+package tui
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"git.sr.ht/~rockorager/vaxis"
+	"github.com/AbeEstrada/tuit/utils"
+	"github.com/mattn/go-mastodon"
+)
+
+// An animation that was not drawn for this long went out of view, so it
+// resumes from the frame it was on instead of skipping ahead
+const animationPauseGap = time.Second
+
+type animationPlayback struct {
+	frame    int
+	elapsed  time.Duration
+	lastDraw time.Time
+	// The draw of the view that last advanced the animation
+	drawn int
+}
+
+// animationPlayer keeps the playback position of the animations on screen and
+// asks for a redraw when the next frame of one is due
+type animationPlayer struct {
+	vx       *vaxis.Vaxis
+	playback map[string]*animationPlayback
+	timer    *time.Timer
+	nextDue  time.Duration
+	// Counts the draws of the view, each ending with Schedule
+	draw int
+}
+
+func newAnimationPlayer(vx *vaxis.Vaxis) *animationPlayer {
+	return &animationPlayer{
+		vx:       vx,
+		playback: make(map[string]*animationPlayback),
+	}
+}
+
+// Selects the frame of the animation to draw now. Called only for animations
+// that are on screen
+func (p *animationPlayer) Advance(url string, anim *utils.AnimatedImage) {
+	if anim.Len() < 2 {
+		return
+	}
+
+	now := time.Now()
+	state, ok := p.playback[url]
+	if !ok {
+		state = &animationPlayback{lastDraw: now}
+		p.playback[url] = state
+	}
+	if gap := now.Sub(state.lastDraw); gap < animationPauseGap {
+		state.elapsed += gap
+	}
+	state.lastDraw = now
+	state.drawn = p.draw
+
+	for state.elapsed >= anim.Delay(state.frame) {
+		state.elapsed -= anim.Delay(state.frame)
+		state.frame = (state.frame + 1) % anim.Len()
+	}
+	anim.SetFrame(state.frame)
+
+	due := anim.Delay(state.frame) - state.elapsed
+	if p.nextDue == 0 || due < p.nextDue {
+		p.nextDue = due
+	}
+}
+
+// Schedules a redraw for the next frame due among the animations advanced since
+// the last call. When none were drawn no redraw is scheduled, which pauses
+// playback until they are visible again. Animations that were not drawn went
+// out of view, so they start over when they come back
+func (p *animationPlayer) Schedule() {
+	for url, state := range p.playback {
+		if state.drawn != p.draw {
+			delete(p.playback, url)
+		}
+	}
+	p.draw++
+
+	due := p.nextDue
+	p.nextDue = 0
+	if due <= 0 {
+		return
+	}
+	if p.timer == nil {
+		p.timer = time.AfterFunc(due, func() {
+			p.vx.PostEvent(vaxis.Redraw{})
+		})
+		return
+	}
+	p.timer.Reset(due)
+}
+
+var animatedExtensions = map[string]bool{
+	".gif":  true,
+	".png":  true,
+	".apng": true,
+	".webp": true,
+}
+
+// Returns the URL of an animated original for the attachment, or an empty
+// string if it has none. Servers convert uploaded GIFs to video, so for gifv
+// the original file is only known for remote media
+func animationURL(media mastodon.Attachment) string {
+	var candidate string
+	switch media.Type {
+	case "gifv":
+		candidate = media.RemoteURL
+	case "image":
+		candidate = media.URL
+	}
+
+	ext := strings.ToLower(path.Ext(urlPath(candidate)))
+	if media.Type == "image" && ext == ".png" && !utils.ImageCache.IsAnimatedPNG(candidate) {
+		// Most PNGs are stills, so the original is only loaded once the start
+		// of the file shows it is an APNG
+		return ""
+	}
+	if !animatedExtensions[ext] {
+		return ""
+	}
+	return candidate
+}
+
+func urlPath(rawURL string) string {
+	if i := strings.IndexAny(rawURL, "?#"); i >= 0 {
+		return rawURL[:i]
+	}
+	return rawURL
+}
@@ -3,6 +3,8 @@ package tui
 import (
 	"context"
 	"log"
+	"path/filepath"
+	"sync"
 
 	"git.sr.ht/~rockorager/vaxis"
 	"github.com/AbeEstrada/tuit/api"
@@ -18,12 +20,33 @@ type App struct {
 	view         View
 	header       *Header
 	footer       *Footer
-	showQuit     bool
+	confirm      *ConfirmModal
+	prompt       *Prompt
 	running      bool
 	loading      bool
 	config       *config.Config
 	client       *mastodon.Client
 	customClient *api.Client
+	emojiMu      sync.RWMutex
+	emojis       map[string]string
+	// Relationships with other accounts, shared by every view
+	relationshipMu sync.RWMutex
+	relationships  map[mastodon.ID]*api.Relationship
+	// Accounts whose relationship has been requested, so it is only loaded once
+	relationshipRequested map[mastodon.ID]bool
+	// Accounts we follow, nil until they load
+	following map[mastodon.ID]bool
+	accountID mastodon.ID
+	// Called on the main loop when the accounts we follow change
+	onFollowsChanged func()
+	// Local mute rules, and the server domain that local accounts belong to
+	muteRules   []muteRule
+	localDomain string
+	// Programs that open media, from the config
+	handlers []mediaHandler
+	// Settings of the account and limits of the server, nil until they load
+	preferences *api.Preferences
+	instance    *mastodon.Instance
 }
 
 func CreateApp() (*App, error) {
@@ -32,6 +55,10 @@ func CreateApp() (*App, error) {
 		auth.SetupAuth()
 		log.Fatalf("Authentication required; re-run after setup")
 	}
+	handlers, err := compileHandlers(cfg.Media.Handlers)
+	if err != nil {
+		return nil, err
+	}
 
 	vx, err := vaxis.New(vaxis.Options{})
 	if err != nil {
@@ -42,22 +69,36 @@ func CreateApp() (*App, error) {
 	views["home"] = CreateHomeView()
 
 	app := &App{
-		vx:       vx,
-		views:    views,
-		view:     views["home"],
-		header:   CreateHeader(),
-		footer:   CreateFooter(vx),
-		showQuit: false,
-		running:  true,
-		loading:  false,
-		config:   cfg,
+		vx:            vx,
+		views:         views,
+		view:          views["home"],
+		header:        CreateHeader(),
+		footer:        CreateFooter(vx),
+		running:       true,
+		loading:       false,
+		config:        cfg,
+		handlers:      handlers,
+		relationships: make(map[mastodon.ID]*api.Relationship),
+
+		relationshipRequested: make(map[mastodon.ID]bool),
 	}
+	app.localDomain = serverDomain(cfg.Auth.Server)
+	app.muteRules = compileMuteRules(cfg.Mute.Rules, app.localDomain)
 
 	for _, view := range views {
 		view.SetApp(app)
 	}
 
-	utils.InitImageCache(vx)
+	imageOpts := utils.ImageCacheOptions{
+		RawLimit:    cfg.Images.RawCacheMB << 20,
+		ScaledLimit: cfg.Images.ScaledCacheMB << 20,
+		DiskLimit:   cfg.Images.DiskCacheMB << 20,
+		Protocol:    cfg.Images.Protocol,
+	}
+	if !cfg.Images.DisableDiskCache {
+		imageOpts.DiskDir = filepath.Join(config.GetCacheDir(), "images")
+	}
+	utils.InitImageCache(vx, imageOpts)
 
 	return app, nil
 }
@@ -86,6 +127,8 @@ func (app *App) Close() {
 func (app *App) draw() {
 	win := app.vx.Window()
 	win.Clear()
+	// Text inputs show the cursor again while they are drawn
+	app.vx.HideCursor()
 
 	app.header.Draw(win)
 
@@ -93,7 +136,15 @@ func (app *App) draw() {
 		app.view.Draw(win)
 	}
 
-	app.footer.Draw(win)
+	if app.prompt != nil {
+		app.prompt.Draw(win)
+	} else {
+		app.footer.Draw(win)
+	}
+
+	if app.confirm != nil {
+		app.confirm.Draw(win)
+	}
 
 	width, height := win.Size()
 	separatorStyle := vaxis.Style{
@@ -110,6 +161,7 @@ func (app *App) draw() {
 	}
 
 	app.vx.Render()
+	utils.ImageCache.EndFrame()
 }
 
 func (app *App) SetLoading(loading bool) {
@@ -133,20 +185,195 @@ func (app *App) initClient() {
 
 	client := mastodon.NewClient(config)
 
-	if _, err := client.GetAccountCurrentUser(context.Background()); err != nil {
+	account, err := client.GetAccountCurrentUser(context.Background())
+	if err != nil {
 		app.Close()
 		log.Fatalf("Failed to authenticate with Mastodon: %v", err)
 	}
+	app.accountID = account.ID
 
 	app.client = client
 	app.customClient = api.NewClient(client)
 
+	if app.config.Mute.HideRepliesToUnfollowed {
+		// Loaded before the timelines so the first page is already muted
+		app.fetchFollowing(account.ID)
+	}
+
+	app.syncPreferences()
+
 	if app.view != nil {
 		app.view.OnActivate()
 	}
 	app.SetLoading(false)
 
 	go app.fetchUnreadNotifications()
+	go app.fetchCustomEmojis()
+	go app.fetchInstance()
+}
+
+// Builds the index of the custom emoji of the server
+func (app *App) fetchCustomEmojis() {
+	emojis, err := app.customClient.GetCustomEmojis(context.Background())
+	if err != nil {
+		log.Printf("Failed to fetch custom emojis: %v", err)
+		return
+	}
+	index := make([]mastodon.Emoji, 0, len(emojis))
+	for _, emoji := range emojis {
+		index = append(index, *emoji)
+	}
+	app.emojiMu.Lock()
+	app.emojis = utils.EmojiURLs(index)
+	app.emojiMu.Unlock()
+	app.vx.PostEvent(vaxis.Redraw{})
+}
+
+// Returns the custom emoji lookup for the given emoji lists, falling back to
+// the emoji of the server for shortcodes the lists do not include
+func (app *App) Emojis(lists ...[]mastodon.Emoji) map[string]string {
+	app.emojiMu.RLock()
+	local := app.emojis
+	app.emojiMu.RUnlock()
+
+	emojis := utils.EmojiURLs(lists...)
+	if len(emojis) == 0 {
+		// The index is replaced as a whole, never modified in place
+		return local
+	}
+	for shortcode, url := range local {
+		if _, ok := emojis[shortcode]; !ok {
+			emojis[shortcode] = url
+		}
+	}
+	return emojis
+}
+
+// Returns the relationship with an account, or nil while it loads
+func (app *App) Relationship(id mastodon.ID) *api.Relationship {
+	app.relationshipMu.RLock()
+	defer app.relationshipMu.RUnlock()
+	return app.relationships[id]
+}
+
+func (app *App) SetRelationships(relationships ...*api.Relationship) {
+	app.relationshipMu.Lock()
+	changed := false
+	for _, relationship := range relationships {
+		if app.followsLocked(relationship.ID) != relationship.Following {
+			changed = true
+		}
+		app.relationships[relationship.ID] = relationship
+	}
+	app.relationshipMu.Unlock()
+	if changed {
+		app.followsChanged()
+	}
+}
+
+// Lets the views apply the mute rules that depend on whom we follow again
+func (app *App) followsChanged() {
+	if app.config.Mute.HideRepliesToUnfollowed && app.onFollowsChanged != nil {
+		app.RunOnMain(app.onFollowsChanged)
+	}
+}
+
+// Loads the relationships with the accounts that have not been requested
+// yet, in the background
+func (app *App) RequestRelationships(ids []mastodon.ID) {
+	app.relationshipMu.Lock()
+	var missing []mastodon.ID
+	for _, id := range ids {
+		if id != "" && !app.relationshipRequested[id] && app.relationships[id] == nil {
+			app.relationshipRequested[id] = true
+			missing = append(missing, id)
+		}
+	}
+	app.relationshipMu.Unlock()
+
+	// The server accepts a limited number of accounts per request
+	for len(missing) > 0 {
+		n := min(len(missing), 40)
+		go app.FetchRelationships(missing[:n])
+		missing = missing[n:]
+	}
+}
+
+// Refreshes the relationships with the accounts
+func (app *App) FetchRelationships(ids []mastodon.ID) {
+	if len(ids) == 0 {
+		return
+	}
+	relationships, err := app.customClient.GetRelationships(context.Background(), ids)
+	if err != nil {
+		log.Printf("Failed to fetch relationships: %v", err)
+		return
+	}
+	app.SetRelationships(relationships...)
+	app.vx.PostEvent(vaxis.Redraw{})
+}
+
+// Loads the posting defaults of the account, and its reading preferences when
+// the config asks to follow the server
+func (app *App) syncPreferences() {
+	prefs, err := app.customClient.GetPreferences(context.Background())
+	if err != nil {
+		log.Printf("Failed to fetch preferences: %v", err)
+		return
+	}
+	app.preferences = prefs
+	if !app.config.Reading.SyncServer {
+		return
+	}
+	app.config.Reading.ExpandSpoilers = prefs.ReadingExpandSpoilers
+	if prefs.ReadingExpandMedia != "" {
+		app.config.Reading.ExpandMedia = prefs.ReadingExpandMedia
+	}
+}
+
+func (app *App) fetchInstance() {
+	instance, err := app.client.GetInstance(context.Background())
+	if err != nil {
+		log.Printf("Failed to fetch instance: %v", err)
+		return
+	}
+	app.instance = instance
+}
+
+// Returns a limit of the server configuration, or fallback before it loads
+func (app *App) instanceLimit(section func(*mastodon.InstanceConfig) *mastodon.InstanceConfigMap, key string, fallback int) int {
+	if app.instance == nil || app.instance.Configuration == nil {
+		return fallback
+	}
+	if values := section(app.instance.Configuration); values != nil {
+		if limit, ok := (*values)[key].(float64); ok && limit > 0 {
+			return int(limit)
+		}
+	}
+	return fallback
+}
+
+// Returns how many characters a status may have on the server
+func (app *App) MaxCharacters() int {
+	return app.instanceLimit(func(c *mastodon.InstanceConfig) *mastodon.InstanceConfigMap { return c.Statuses }, "max_characters", 500)
+}
+
+// Returns how many options a poll may have on the server
+func (app *App) MaxPollOptions() int {
+	return app.instanceLimit(func(c *mastodon.InstanceConfig) *mastodon.InstanceConfigMap { return c.Polls }, "max_options", 4)
+}
+
+// Returns how many attachments a status may have on the server
+func (app *App) MaxMediaAttachments() int {
+	return app.instanceLimit(func(c *mastodon.InstanceConfig) *mastodon.InstanceConfigMap { return c.Statuses }, "max_media_attachments", 4)
+}
+
+// Returns the visibility of new statuses
+func (app *App) DefaultVisibility() string {
+	if app.preferences != nil && app.preferences.PostingDefaultVisibility != "" {
+		return app.preferences.PostingDefaultVisibility
+	}
+	return "public"
 }
 
 func (app *App) fetchUnreadNotifications() {
@@ -164,25 +391,79 @@ func (app *App) handleEvent() {
 	if event == nil {
 		return
 	}
-	if key, ok := event.(vaxis.Key); ok {
-		app.handleKeyEvent(key)
+	switch ev := event.(type) {
+	case vaxis.Key:
+		app.handleKeyEvent(ev)
+	case vaxis.PasteEndEvent:
+		if app.prompt != nil {
+			app.prompt.HandleEvent(ev)
+		}
+	case vaxis.Resize:
+		if ev.Cols > 0 && ev.Rows > 0 {
+			utils.ImageCache.SetCellSize(ev.XPixel/ev.Cols, ev.YPixel/ev.Rows)
+		}
+	case mainEvent:
+		ev()
 	}
 }
 
+// mainEvent carries work from a goroutine to the main loop
+type mainEvent func()
+
+// Runs fn on the main loop, between draws, so background work can hand its
+// results to the views without racing with them. The screen is redrawn after
+func (app *App) RunOnMain(fn func()) {
+	app.vx.PostEvent(mainEvent(fn))
+}
+
 func (app *App) RequestQuit() {
-	app.showQuit = true
-	app.footer.SetText("Quit?")
+	app.ShowConfirm("Are you sure you want to quit?", func() {
+		app.running = false
+	})
+}
+
+// Asks a yes or no question in a modal. onConfirm is called when the answer
+// is yes, and not at all otherwise
+func (app *App) ShowConfirm(message string, onConfirm func()) {
+	app.confirm = CreateConfirmModal(message, onConfirm)
+	app.vx.PostEvent(vaxis.Redraw{})
+}
+
+// Asks for a line of text in the footer. onSubmit is called with the text
+// when it is entered, and not at all when the prompt is dismissed
+func (app *App) ShowPrompt(label, text string, onSubmit func(text string)) {
+	app.prompt = CreatePrompt(label, text, onSubmit)
+	app.vx.PostEvent(vaxis.Redraw{})
+}
+
+// Asks for the path of a file in the footer, Tab completing it
+func (app *App) ShowPathPrompt(label string, onSubmit func(path string)) {
+	app.prompt = CreatePrompt(label, "", onSubmit)
+	app.prompt.complete = utils.CompletePath
 	app.vx.PostEvent(vaxis.Redraw{})
 }
 
 func (app *App) handleKeyEvent(key vaxis.Key) {
-	if app.showQuit {
-		if key.Matches('y') || key.Matches(vaxis.KeyEnter) {
-			app.running = false
-		} else if key.Matches('n') || key.Matches(vaxis.KeyEsc) || key.Matches('q') {
-			app.showQuit = false
-			app.footer.SetText("")
+	if app.prompt != nil {
+		prompt := app.prompt
+		switch prompt.HandleEvent(key) {
+		case "submit":
+			app.prompt = nil
+			prompt.onSubmit(prompt.String())
+		case "close":
+			app.prompt = nil
+		}
+		return
+	}
 
+	if app.confirm != nil {
+		confirm := app.confirm
+		switch confirm.HandleKey(key) {
+		case "confirm":
+			app.confirm = nil
+			confirm.onConfirm()
+		case "close":
+			app.confirm = nil
 		}
 		return
 	}
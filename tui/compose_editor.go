@@ -0,0 +1,168 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-mastodon"
+)
+
+// Line that opens and closes the header of a draft edited externally
+const frontMatterDelimiter = "---"
+
+// draftHeader holds the settings of a draft written above its text when it is
+// edited externally
+type draftHeader struct {
+	visibility string
+	cw         string
+	language   string
+	inReplyTo  mastodon.ID
+	sensitive  bool
+}
+
+// Returns the draft as a file: the header between delimiter lines, then the
+// text
+func formatDraft(header draftHeader, text string) string {
+	var b strings.Builder
+	field := func(key, value string) {
+		b.WriteString(strings.TrimRight(key+": "+value, " ") + "\n")
+	}
+	b.WriteString(frontMatterDelimiter + "\n")
+	field("visibility", header.visibility)
+	field("cw", header.cw)
+	field("language", header.language)
+	field("in_reply_to", string(header.inReplyTo))
+	field("sensitive", strconv.FormatBool(header.sensitive))
+	b.WriteString(frontMatterDelimiter + "\n")
+	b.WriteString(text)
+	return b.String()
+}
+
+// Reads a draft written by formatDraft. Fields missing from the header keep
+// their value in header, and a file without a header is all text
+func parseDraft(data string, header draftHeader) (draftHeader, string, error) {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	rest, ok := strings.CutPrefix(data, frontMatterDelimiter+"\n")
+	if !ok {
+		return header, data, nil
+	}
+
+	for {
+		line, next, found := strings.Cut(rest, "\n")
+		rest = next
+		if strings.TrimSpace(line) == frontMatterDelimiter {
+			break
+		}
+		if !found {
+			return header, "", fmt.Errorf("the header does not end with %s", frontMatterDelimiter)
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return header, "", fmt.Errorf("not a header field: %s", line)
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "visibility":
+			if !slices.Contains(visibilities, value) {
+				return header, "", fmt.Errorf("visibility is one of %s", strings.Join(visibilities, ", "))
+			}
+			header.visibility = value
+		case "cw":
+			header.cw = value
+		case "language":
+			header.language = value
+		case "in_reply_to":
+			header.inReplyTo = mastodon.ID(value)
+		case "sensitive":
+			sensitive, err := strconv.ParseBool(value)
+			if err != nil {
+				return header, "", fmt.Errorf("sensitive is true or false")
+			}
+			header.sensitive = sensitive
+		default:
+			return header, "", fmt.Errorf("unknown header field: %s", key)
+		}
+	}
+	return header, rest, nil
+}
+
+func (v *ComposeView) header() draftHeader {
+	header := draftHeader{
+		visibility: v.visibility,
+		cw:         v.spoiler.String(),
+		language:   v.language,
+		sensitive:  v.sensitive,
+	}
+	if v.inReplyTo != nil {
+		header.inReplyTo = v.inReplyTo.ID
+	}
+	return header
+}
+
+// Opens the draft in the external editor, then brings the text and settings
+// back for review. Attachments and the poll stay as they are
+func (v *ComposeView) editExternally() {
+	header := v.header()
+	data, err := v.app.EditExternally(formatDraft(header, v.text.String()), ".md")
+	if err != nil {
+		log.Printf("Failed to edit draft: %v", err)
+		v.app.footer.SetText("Failed to run the editor")
+		return
+	}
+
+	edited, text, err := parseDraft(data, header)
+	if err != nil {
+		// Nothing written is lost, the header is left in the text to fix
+		v.app.footer.SetText("Could not read the draft: " + err.Error())
+		v.text.SetText(strings.TrimRight(data, "\n"))
+		return
+	}
+	v.text.SetText(strings.TrimRight(text, "\n"))
+	v.spoiler.SetContent(edited.cw)
+	v.language = edited.language
+	v.sensitive = edited.sensitive
+
+	// An edit keeps the visibility and the status it replies to
+	if v.editing != nil {
+		if edited.visibility != header.visibility || edited.inReplyTo != header.inReplyTo {
+			v.app.footer.SetText("The visibility and reply of a post cannot change")
+		}
+		return
+	}
+	v.visibility = edited.visibility
+	if edited.inReplyTo == "" {
+		v.inReplyTo = nil
+		v.loadingReply = ""
+	} else if edited.inReplyTo != header.inReplyTo {
+		v.loadingReply = edited.inReplyTo
+		go v.loadInReplyTo(edited.inReplyTo)
+	}
+}
+
+// Loads the status a draft replies to after its ID was changed. The status is
+// set on the main loop, unless the draft changed the reply again meanwhile
+func (v *ComposeView) loadInReplyTo(id mastodon.ID) {
+	status, err := v.app.client.GetStatus(context.Background(), id)
+	if err != nil {
+		log.Printf("Failed to fetch status %s: %v", id, err)
+	}
+	v.app.RunOnMain(func() {
+		if v.loadingReply != id {
+			return
+		}
+		v.loadingReply = ""
+		if err != nil {
+			v.app.footer.SetText("Failed to load the status to reply to")
+		} else {
+			v.inReplyTo = status
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDraftRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		header draftHeader
+		text   string
+	}{
+		{"empty", draftHeader{visibility: "public"}, ""},
+		{
+			"every field",
+			draftHeader{visibility: "direct", cw: "spoilers: ahead", language: "es", inReplyTo: "109", sensitive: true},
+			"Hello\n\nWorld\n",
+		},
+		{"text with a delimiter", draftHeader{visibility: "unlisted"}, "above\n---\nbelow"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, text, err := parseDraft(formatDraft(tt.header, tt.text), draftHeader{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header != tt.header {
+				t.Errorf("header = %+v, want %+v", header, tt.header)
+			}
+			if text != tt.text {
+				t.Errorf("text = %q, want %q", text, tt.text)
+			}
+		})
+	}
+}
+
+func TestParseDraft(t *testing.T) {
+	base := draftHeader{visibility: "private", language: "en", inReplyTo: "1"}
+	tests := []struct {
+		name       string
+		data       string
+		wantHeader draftHeader
+		wantText   string
+		wantErr    string
+	}{
+		{
+			name:       "no header",
+			data:       "just text\n",
+			wantHeader: base,
+			wantText:   "just text\n",
+		},
+		{
+			name:       "missing fields keep their value",
+			data:       "---\ncw: careful\n---\ntext",
+			wantHeader: draftHeader{visibility: "private", cw: "careful", language: "en", inReplyTo: "1"},
+			wantText:   "text",
+		},
+		{
+			name:       "crlf line endings",
+			data:       "---\r\nvisibility: public\r\nsensitive: true\r\n---\r\nline one\r\nline two",
+			wantHeader: draftHeader{visibility: "public", language: "en", inReplyTo: "1", sensitive: true},
+			wantText:   "line one\nline two",
+		},
+		{
+			name:       "blank lines and spacing",
+			data:       "---\n\n  language :  fr  \n\n---\n",
+			wantHeader: draftHeader{visibility: "private", language: "fr", inReplyTo: "1"},
+			wantText:   "",
+		},
+		{
+			name:       "empty reply clears it",
+			data:       "---\nin_reply_to:\n---\n",
+			wantHeader: draftHeader{visibility: "private", language: "en"},
+		},
+		{name: "unterminated header", data: "---\nvisibility: public\ntext", wantErr: "does not end"},
+		{name: "unknown field", data: "---\nmood: happy\n---\n", wantErr: "unknown header field: mood"},
+		{name: "not a field", data: "---\njust words\n---\n", wantErr: "not a header field"},
+		{name: "bad visibility", data: "---\nvisibility: everyone\n---\n", wantErr: "visibility is one of"},
+		{name: "bad sensitive", data: "---\nsensitive: maybe\n---\n", wantErr: "sensitive is true or false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, text, err := parseDraft(tt.data, base)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header != tt.wantHeader {
+				t.Errorf("header = %+v, want %+v", header, tt.wantHeader)
+			}
+			if text != tt.wantText {
+				t.Errorf("text = %q, want %q", text, tt.wantText)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.sr.ht/~rockorager/vaxis"
+	"git.sr.ht/~rockorager/vaxis/widgets/textinput"
+	"github.com/AbeEstrada/tuit/config"
+	"github.com/AbeEstrada/tuit/utils"
+	"github.com/mattn/go-mastodon"
+	"github.com/rivo/uniseg"
+)
+
+// How long the server may take to process an upload, videos mostly
+const mediaProcessingTimeout = 5 * time.Minute
+
+// Size in cells of the thumbnails of attachments
+const (
+	thumbnailWidth  = 6
+	thumbnailHeight = 3
+)
+
+// composeMedia is an attachment of a draft
+type composeMedia struct {
+	attachment  mastodon.Attachment
+	description *textinput.Model
+	// Path of a file attached from disk, empty for the attachments of a
+	// status being edited
+	path      string
+	uploading bool
+	failed    bool
+	// Whether the focal point was changed, so it is saved
+	focusSet bool
+}
+
+func newComposeMedia(attachment mastodon.Attachment) *composeMedia {
+	return &composeMedia{
+		attachment:  attachment,
+		description: textinput.New().SetContent(attachment.Description),
+	}
+}
+
+// Returns the focal point of the attachment in the form the API takes
+func (m *composeMedia) focus() string {
+	focus := m.attachment.Meta.Focus
+	return strconv.FormatFloat(focus.X, 'f', 2, 64) + "," + strconv.FormatFloat(focus.Y, 'f', 2, 64)
+}
+
+// Reports whether the description or focal point differ from those saved
+// with the attachment
+func (m *composeMedia) changed() bool {
+	return m.focusSet || strings.TrimSpace(m.description.String()) != m.attachment.Description
+}
+
+func (m *composeMedia) label() string {
+	name := utils.TitleCase(m.attachment.Type)
+	if m.path != "" {
+		name = filepath.Base(m.path)
+	}
+	switch {
+	case m.uploading:
+		return name + " · Uploading..."
+	case m.failed:
+		return name + " · Upload failed"
+	}
+	return name
+}
+
+// Parses a focal point typed as "x,y", each from -1.0 to 1.0
+func parseFocus(text string) (mastodon.AttachmentFocus, bool) {
+	xText, yText, ok := strings.Cut(text, ",")
+	if !ok {
+		return mastodon.AttachmentFocus{}, false
+	}
+	x, errX := strconv.ParseFloat(strings.TrimSpace(xText), 64)
+	y, errY := strconv.ParseFloat(strings.TrimSpace(yText), 64)
+	// Written so NaN, which compares false to everything, is out of range
+	if errX != nil || errY != nil || !(x >= -1 && x <= 1) || !(y >= -1 && y <= 1) {
+		return mastodon.AttachmentFocus{}, false
+	}
+	return mastodon.AttachmentFocus{X: x, Y: y}, true
+}
+
+// Asks for a file to attach. A status has either attachments or a poll
+func (v *ComposeView) attachMedia() {
+	if v.poll != nil {
+		v.app.footer.SetText("Remove the poll to attach media")
+		return
+	}
+	if limit := v.app.MaxMediaAttachments(); len(v.media) >= limit {
+		v.app.footer.SetText(fmt.Sprintf("A post can have up to %d attachments", limit))
+		return
+	}
+
+	v.app.ShowPathPrompt("Attach file", func(path string) {
+		path = utils.ExpandHome(strings.TrimSpace(path))
+		if path == "" {
+			return
+		}
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			v.app.footer.SetText("Not a file: " + path)
+			return
+		}
+		media := &composeMedia{
+			description: textinput.New(),
+			path:        path,
+			uploading:   true,
+		}
+		v.media = append(v.media, media)
+		go v.upload(media)
+	})
+}
+
+// Uploads the file of an attachment, then waits for the server to process it
+func (v *ComposeView) upload(media *composeMedia) {
+	ctx := context.Background()
+	attachment, err := v.uploadFile(ctx, media.path)
+	deadline := time.Now().Add(mediaProcessingTimeout)
+	for err == nil && attachment.URL == "" {
+		if time.Now().After(deadline) {
+			err = fmt.Errorf("still processing after %v", mediaProcessingTimeout)
+			break
+		}
+		time.Sleep(time.Second)
+		attachment, err = v.app.customClient.GetMedia(ctx, attachment.ID)
+	}
+
+	if err != nil {
+		log.Printf("Failed to upload %s: %v", media.path, err)
+	}
+	// The draft is only changed on the main loop, where it is drawn
+	v.app.RunOnMain(func() {
+		media.uploading = false
+		if err != nil {
+			media.failed = true
+			v.app.footer.SetText("Failed to upload " + filepath.Base(media.path))
+		} else {
+			media.attachment = *attachment
+		}
+	})
+}
+
+func (v *ComposeView) uploadFile(ctx context.Context, path string) (*mastodon.Attachment, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return v.app.client.UploadMediaFromMedia(ctx, &mastodon.Media{File: file})
+}
+
+// Removes the attachment being described from the draft
+func (v *ComposeView) removeMedia() {
+	index := v.focusedMedia()
+	if index < 0 {
+		return
+	}
+	v.media = slices.Delete(v.media, index, index+1)
+	v.focus = min(v.focus, v.fields()-1)
+}
+
+// Asks for the point of the attachment being described that stays in view
+// when its preview is cropped
+func (v *ComposeView) editFocalPoint() {
+	index := v.focusedMedia()
+	if index < 0 {
+		return
+	}
+	media := v.media[index]
+	v.app.ShowPrompt("Focal point (x,y from -1 to 1)", media.focus(), func(text string) {
+		focus, ok := parseFocus(text)
+		if !ok {
+			v.app.footer.SetText("A focal point is two numbers from -1 to 1, such as 0,0.5")
+			return
+		}
+		media.attachment.Meta.Focus = focus
+		media.focusSet = true
+	})
+}
+
+// Returns the reason the attachments cannot be posted yet, if any
+func (v *ComposeView) mediaProblem() string {
+	for _, media := range v.media {
+		if media.uploading {
+			return "Wait for the uploads to finish"
+		}
+		if media.failed {
+			return "Remove the uploads that failed"
+		}
+	}
+	if v.app.config.Compose.AltText == config.AltTextRequire && v.undescribedMedia() > 0 {
+		return "Describe every attachment before posting"
+	}
+	return ""
+}
+
+// Returns the number of attachments without a description
+func (v *ComposeView) undescribedMedia() int {
+	count := 0
+	for _, media := range v.media {
+		if strings.TrimSpace(media.description.String()) == "" {
+			count++
+		}
+	}
+	return count
+}
+
+// Returns the rows each attachment takes, more with thumbnails
+func mediaRowHeight() int {
+	if utils.ImageCache.Enabled() {
+		return thumbnailHeight
+	}
+	return 1
+}
+
+// Draws the attachments from row y, each with its thumbnail when images are
+// shown, its description, and the field to edit it when focused
+func (v *ComposeView) drawMedia(win vaxis.Window, y int) {
+	width, _ := win.Size()
+	boldStyle := vaxis.Style{Attribute: vaxis.AttrBold}
+	dimStyle := vaxis.Style{Attribute: vaxis.AttrDim}
+	warnStyle := vaxis.Style{Foreground: vaxis.IndexColor(3)}
+
+	win.New(0, y, width, 1).PrintTruncate(0, vaxis.Segment{Text: "Media", Style: boldStyle})
+	rowHeight := mediaRowHeight()
+	x := 0
+	if rowHeight > 1 {
+		x = thumbnailWidth + 1
+	}
+	for i, media := range v.media {
+		top := y + 1 + i*rowHeight
+		if rowHeight > 1 && media.attachment.PreviewURL != "" {
+			if img, ok := utils.ImageCache.GetFilled(media.attachment.PreviewURL, thumbnailWidth, thumbnailHeight); ok {
+				img.Draw(win.New(0, top, thumbnailWidth, thumbnailHeight))
+			}
+		}
+
+		// Without thumbnails the description follows the label on its row
+		label := fmt.Sprintf("%d. %s", i+1, media.label())
+		descriptionWin := win.New(x, top+1, width-x, 1)
+		if rowHeight == 1 {
+			label += ": "
+			labelWidth := uniseg.StringWidth(label)
+			descriptionWin = win.New(labelWidth, top, width-labelWidth, 1)
+		}
+		win.New(x, top, width-x, 1).PrintTruncate(0, vaxis.Segment{Text: label})
+		if v.focus == composeFocusMedia+i {
+			media.description.Draw(descriptionWin)
+		} else if text := media.description.String(); text != "" {
+			descriptionWin.PrintTruncate(0, vaxis.Segment{Text: text})
+		} else if v.app.config.Compose.AltText == config.AltTextOff {
+			descriptionWin.PrintTruncate(0, vaxis.Segment{Text: "No description", Style: dimStyle})
+		} else {
+			descriptionWin.PrintTruncate(0, vaxis.Segment{Text: "No description", Style: warnStyle})
+		}
+	}
+}
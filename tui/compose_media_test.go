@@ -0,0 +1,34 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func TestParseFocus(t *testing.T) {
+	tests := []struct {
+		text   string
+		want   mastodon.AttachmentFocus
+		wantOK bool
+	}{
+		{"0,0", mastodon.AttachmentFocus{}, true},
+		{"0.5,-0.25", mastodon.AttachmentFocus{X: 0.5, Y: -0.25}, true},
+		{" -1 , 1 ", mastodon.AttachmentFocus{X: -1, Y: 1}, true},
+		{"1.01,0", mastodon.AttachmentFocus{}, false},
+		{"0,-1.5", mastodon.AttachmentFocus{}, false},
+		{"NaN,0", mastodon.AttachmentFocus{}, false},
+		{"0,Inf", mastodon.AttachmentFocus{}, false},
+		{"0.5", mastodon.AttachmentFocus{}, false},
+		{"a,b", mastodon.AttachmentFocus{}, false},
+		{"0.5,", mastodon.AttachmentFocus{}, false},
+		{"0,0,0", mastodon.AttachmentFocus{}, false},
+		{"", mastodon.AttachmentFocus{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseFocus(tt.text)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("parseFocus(%q) = (%+v, %v), want (%+v, %v)", tt.text, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,711 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"git.sr.ht/~rockorager/vaxis"
+	"git.sr.ht/~rockorager/vaxis/widgets/textinput"
+	"github.com/AbeEstrada/tuit/api"
+	"github.com/AbeEstrada/tuit/config"
+	"github.com/mattn/go-mastodon"
+	"github.com/rivo/uniseg"
+)
+
+// Visibilities from the most to the least public, in the order the composer
+// cycles through them
+var visibilities = []string{"public", "unlisted", "private", "direct"}
+
+func visibilityLabel(visibility string) string {
+	switch visibility {
+	case "unlisted":
+		return "Quiet public"
+	case "private":
+		return "Followers only"
+	case "direct":
+		return "Mentioned people only"
+	default:
+		return "Public"
+	}
+}
+
+// Returns the least public of two visibilities
+func narrowestVisibility(a, b string) string {
+	if slices.Index(visibilities, b) > slices.Index(visibilities, a) {
+		return b
+	}
+	return a
+}
+
+// Returns the mentions a reply starts with: the author and everyone the
+// status mentions, except ourselves
+func replyMentions(status *mastodon.Status, accountID mastodon.ID, participants []*mastodon.Account) string {
+	var mentions []string
+	add := func(id mastodon.ID, acct string) {
+		if id != accountID && !slices.Contains(mentions, "@"+acct) {
+			mentions = append(mentions, "@"+acct)
+		}
+	}
+	add(status.Account.ID, status.Account.Acct)
+	for _, mention := range status.Mentions {
+		add(mention.ID, mention.Acct)
+	}
+	for _, account := range participants {
+		add(account.ID, account.Acct)
+	}
+	if len(mentions) == 0 {
+		return ""
+	}
+	return strings.Join(mentions, " ") + " "
+}
+
+type pollDuration struct {
+	label    string
+	duration time.Duration
+}
+
+var pollDurations = []pollDuration{
+	{"5 minutes", 5 * time.Minute},
+	{"30 minutes", 30 * time.Minute},
+	{"1 hour", time.Hour},
+	{"6 hours", 6 * time.Hour},
+	{"12 hours", 12 * time.Hour},
+	{"1 day", 24 * time.Hour},
+	{"3 days", 3 * 24 * time.Hour},
+	{"7 days", 7 * 24 * time.Hour},
+}
+
+// Returns the duration offered that is closest to d
+func nearestPollDuration(d time.Duration) pollDuration {
+	nearest := pollDurations[0]
+	for _, choice := range pollDurations[1:] {
+		if (choice.duration - d).Abs() < (nearest.duration - d).Abs() {
+			nearest = choice
+		}
+	}
+	return nearest
+}
+
+// composePoll is the poll of a draft
+type composePoll struct {
+	options    []*textinput.Model
+	duration   pollDuration
+	multiple   bool
+	hideTotals bool
+	// Set while the poll of an edited status keeps the time it ends at, until
+	// another duration is picked
+	keepsEnd bool
+}
+
+func newPollOption(text string) *textinput.Model {
+	return textinput.New().SetContent(text)
+}
+
+// Fields of the composer that take the keys. The attachments follow the
+// content warning, and the options of the poll follow them
+const (
+	composeFocusText = iota
+	composeFocusCW
+	composeFocusMedia
+)
+
+// ComposeView writes a new status or a reply in place of the detail pane
+type ComposeView struct {
+	app       *App
+	text      *TextArea
+	spoiler   *textinput.Model
+	media     []*composeMedia
+	poll      *composePoll
+	focus     int
+	inReplyTo *mastodon.Status
+	// The status being edited, nil for a new one
+	editing    *mastodon.Status
+	visibility string
+	language   string
+	sensitive  bool
+	posting    bool
+	// The status to reply to while it loads after an external edit
+	loadingReply mastodon.ID
+	menu         *MenuView
+	// Asking whether to discard the draft, or changing the poll
+	showingMenu  bool
+	onMenuSelect func(index int)
+	onPost       func(status, inReplyTo *mastodon.Status)
+	onEdit       func(status *mastodon.Status)
+	onClose      func()
+}
+
+func CreateComposeView() *ComposeView {
+	return &ComposeView{
+		text: CreateTextArea(),
+		menu: CreateMenuView(),
+	}
+}
+
+func (v *ComposeView) SetApp(app *App) {
+	v.app = app
+}
+
+// Starts a draft. A reply mentions the people in the status it answers and
+// keeps its content warning
+func (v *ComposeView) Open(inReplyTo *mastodon.Status, visibility string, participants []*mastodon.Account) {
+	text := ""
+	spoiler := ""
+	if inReplyTo != nil {
+		text = replyMentions(inReplyTo, v.app.accountID, participants)
+		spoiler = inReplyTo.SpoilerText
+	}
+	v.reset(text, spoiler)
+	v.inReplyTo = inReplyTo
+	v.visibility = visibility
+	if prefs := v.app.preferences; prefs != nil {
+		v.language = prefs.PostingDefaultLanguage
+	}
+}
+
+// Starts editing one of our statuses from its source. The visibility of a
+// status cannot change, and its poll keeps the time it ends at unless another
+// duration is picked
+func (v *ComposeView) OpenEdit(status *mastodon.Status, source *mastodon.Source) {
+	v.reset(source.Text, source.SpoilerText)
+	v.editing = status
+	v.fill(status, pollDuration{label: "Unchanged"})
+	if poll := status.Poll; poll != nil {
+		v.poll.keepsEnd = true
+	}
+}
+
+// Starts a draft from a status that was deleted, keeping its attachments and
+// poll. inReplyTo is the status it answered, if any
+func (v *ComposeView) OpenRedraft(status *mastodon.Status, source *mastodon.Source, inReplyTo *mastodon.Status) {
+	v.reset(source.Text, source.SpoilerText)
+	v.inReplyTo = inReplyTo
+	duration := pollDurations[5]
+	if poll := status.Poll; poll != nil {
+		duration = nearestPollDuration(poll.ExpiresAt.Sub(status.CreatedAt))
+	}
+	v.fill(status, duration)
+}
+
+// Fills the draft with the settings, attachments and poll of a status
+func (v *ComposeView) fill(status *mastodon.Status, duration pollDuration) {
+	v.visibility = status.Visibility
+	v.language = status.Language
+	v.sensitive = status.Sensitive
+	for _, attachment := range status.MediaAttachments {
+		v.media = append(v.media, newComposeMedia(attachment))
+	}
+	if poll := status.Poll; poll != nil {
+		v.poll = &composePoll{
+			duration: duration,
+			multiple: poll.Multiple,
+		}
+		for _, option := range poll.Options {
+			v.poll.options = append(v.poll.options, newPollOption(option.Title))
+		}
+	}
+}
+
+func (v *ComposeView) reset(text, spoiler string) {
+	v.text.SetText(text)
+	v.spoiler = textinput.New().SetPrompt("CW: ").SetContent(spoiler)
+	v.spoiler.Prompt = vaxis.Style{Attribute: vaxis.AttrBold}
+	v.media = nil
+	v.poll = nil
+	v.focus = composeFocusText
+	v.inReplyTo = nil
+	v.editing = nil
+	v.visibility = ""
+	v.language = ""
+	v.sensitive = false
+	v.posting = false
+	v.loadingReply = ""
+	v.showingMenu = false
+}
+
+// Returns the number of characters the status still has room for
+func (v *ComposeView) remaining() int {
+	return v.app.MaxCharacters() - v.text.Len() - uniseg.GraphemeClusterCount(v.spoiler.String())
+}
+
+// Returns the field of the first poll option
+func (v *ComposeView) pollFocus() int {
+	return composeFocusMedia + len(v.media)
+}
+
+// Returns the number of fields the focus moves through
+func (v *ComposeView) fields() int {
+	if v.poll == nil {
+		return v.pollFocus()
+	}
+	return v.pollFocus() + len(v.poll.options)
+}
+
+// Returns the index of the attachment being described, or -1
+func (v *ComposeView) focusedMedia() int {
+	if v.focus < composeFocusMedia || v.focus >= v.pollFocus() {
+		return -1
+	}
+	return v.focus - composeFocusMedia
+}
+
+// Returns the poll option being edited, or nil
+func (v *ComposeView) focusedOption() *textinput.Model {
+	if v.poll == nil || v.focus < v.pollFocus() {
+		return nil
+	}
+	return v.poll.options[v.focus-v.pollFocus()]
+}
+
+// Returns the poll to post, or an error text when it is not complete
+func (v *ComposeView) tootPoll() (*mastodon.TootPoll, string) {
+	if v.poll == nil {
+		return nil, ""
+	}
+	var options []string
+	for _, option := range v.poll.options {
+		if text := strings.TrimSpace(option.String()); text != "" {
+			options = append(options, text)
+		}
+	}
+	if len(options) < 2 {
+		return nil, "A poll needs at least two options"
+	}
+	expiresIn := v.poll.duration.duration
+	if v.poll.keepsEnd && v.editing != nil && v.editing.Poll != nil {
+		expiresIn = time.Until(v.editing.Poll.ExpiresAt)
+	}
+	return &mastodon.TootPoll{
+		Options:          options,
+		ExpiresInSeconds: int64(expiresIn.Seconds()),
+		Multiple:         v.poll.multiple,
+		HideTotals:       v.poll.hideTotals,
+	}, ""
+}
+
+// Returns the poll to send with an edit, nil when the status keeps the poll it
+// has, since sending a poll again restarts it. A poll that ended stays closed
+func (v *ComposeView) editedPoll(poll *mastodon.TootPoll) (*mastodon.TootPoll, string) {
+	previous := v.editing.Poll
+	if previous == nil || poll == nil {
+		return poll, ""
+	}
+	titles := make([]string, len(previous.Options))
+	for i, option := range previous.Options {
+		titles[i] = option.Title
+	}
+	if v.poll.keepsEnd && poll.Multiple == previous.Multiple && !poll.HideTotals && slices.Equal(poll.Options, titles) {
+		return nil, ""
+	}
+	if pollClosed(previous) {
+		return nil, "The poll has ended and can no longer be changed"
+	}
+	return poll, ""
+}
+
+// Returns the status to post from the draft, with the attachments whose
+// description or focal point have to be saved before it
+func (v *ComposeView) toot(poll *mastodon.TootPoll) (*mastodon.Toot, []api.MediaAttribute) {
+	toot := &mastodon.Toot{
+		Status:      strings.TrimSpace(v.text.String()),
+		SpoilerText: strings.TrimSpace(v.spoiler.String()),
+		Visibility:  v.visibility,
+		Language:    v.language,
+		Sensitive:   v.sensitive,
+		Poll:        poll,
+	}
+	if v.inReplyTo != nil {
+		toot.InReplyToID = v.inReplyTo.ID
+	}
+	var described []api.MediaAttribute
+	for _, media := range v.media {
+		if media.changed() {
+			described = append(described, api.MediaAttribute{
+				ID:          media.attachment.ID,
+				Description: strings.TrimSpace(media.description.String()),
+				Focus:       media.focus(),
+			})
+		}
+		toot.MediaIDs = append(toot.MediaIDs, media.attachment.ID)
+	}
+	return toot, described
+}
+
+// Returns the new version of the status being edited from the draft
+func (v *ComposeView) statusEdit(poll *mastodon.TootPoll) *api.StatusEdit {
+	edit := &api.StatusEdit{
+		Status:      strings.TrimSpace(v.text.String()),
+		SpoilerText: strings.TrimSpace(v.spoiler.String()),
+		Sensitive:   v.sensitive,
+		Language:    v.language,
+		Poll:        poll,
+	}
+	for _, media := range v.media {
+		edit.MediaIDs = append(edit.MediaIDs, media.attachment.ID)
+		edit.MediaAttributes = append(edit.MediaAttributes, api.MediaAttribute{
+			ID:          media.attachment.ID,
+			Description: strings.TrimSpace(media.description.String()),
+			Focus:       media.focus(),
+		})
+	}
+	return edit
+}
+
+// Sends the draft, read on the main loop, from the background. The composer
+// takes no keys until the answer is back on the main loop
+func (v *ComposeView) submit(poll *mastodon.TootPoll) {
+	v.posting = true
+	if v.editing != nil {
+		go v.edit(v.editing.ID, v.statusEdit(poll))
+	} else {
+		toot, described := v.toot(poll)
+		go v.post(toot, described, v.inReplyTo)
+	}
+}
+
+func (v *ComposeView) post(toot *mastodon.Toot, described []api.MediaAttribute, inReplyTo *mastodon.Status) {
+	// Attachments are described apart from the status that posts them
+	ctx := context.Background()
+	for _, attribute := range described {
+		if _, err := v.app.customClient.UpdateMedia(ctx, &attribute); err != nil {
+			log.Printf("Failed to describe media: %v", err)
+			v.app.RunOnMain(func() {
+				v.app.footer.SetText("Failed to save the description")
+				v.posting = false
+			})
+			return
+		}
+	}
+
+	status, err := v.app.client.PostStatus(ctx, toot)
+	if err != nil {
+		log.Printf("Failed to post: %v", err)
+	}
+	v.app.RunOnMain(func() {
+		v.posting = false
+		if err != nil {
+			v.app.footer.SetText("Failed to post")
+			return
+		}
+		v.app.footer.SetText("Posted")
+		if v.onPost != nil {
+			v.onPost(status, inReplyTo)
+		}
+		v.close()
+	})
+}
+
+func (v *ComposeView) edit(id mastodon.ID, edit *api.StatusEdit) {
+	status, err := v.app.customClient.EditStatus(context.Background(), id, edit)
+	if err != nil {
+		log.Printf("Failed to edit status: %v", err)
+	}
+	v.app.RunOnMain(func() {
+		v.posting = false
+		if err != nil {
+			v.app.footer.SetText("Failed to save the edit")
+			return
+		}
+		v.app.footer.SetText("Edited")
+		if v.onEdit != nil {
+			v.onEdit(status)
+		}
+		v.close()
+	})
+}
+
+func (v *ComposeView) close() {
+	v.showingMenu = false
+	if v.onClose != nil {
+		v.onClose()
+	}
+}
+
+func (v *ComposeView) openMenu(title string, items []MenuItem, onSelect func(index int)) {
+	v.menu.SetItems(title, items, false)
+	v.onMenuSelect = onSelect
+	v.showingMenu = true
+}
+
+// Adds a poll to the draft, or offers to change the one it has
+func (v *ComposeView) editPoll() {
+	if v.editing != nil && v.editing.Poll != nil && pollClosed(v.editing.Poll) {
+		v.app.footer.SetText("The poll has ended and can no longer be changed")
+		return
+	}
+	if v.poll == nil && len(v.media) > 0 {
+		v.app.footer.SetText("Remove the media to add a poll")
+		return
+	}
+	if v.poll == nil {
+		v.poll = &composePoll{
+			options:  []*textinput.Model{newPollOption(""), newPollOption("")},
+			duration: pollDurations[5],
+		}
+		v.focus = v.pollFocus()
+		return
+	}
+
+	onOff := func(on bool) string {
+		if on {
+			return "on"
+		}
+		return "off"
+	}
+	type action struct {
+		label string
+		run   func()
+	}
+	actions := []action{
+		{"Duration: " + v.poll.duration.label, func() {
+			items := make([]MenuItem, len(pollDurations))
+			for i, d := range pollDurations {
+				items[i] = MenuItem{Label: d.label}
+			}
+			v.openMenu("Poll duration", items, func(index int) {
+				v.poll.duration = pollDurations[index]
+				v.poll.keepsEnd = false
+			})
+		}},
+		{"Multiple choice: " + onOff(v.poll.multiple), func() {
+			v.poll.multiple = !v.poll.multiple
+		}},
+		{"Hide totals until it closes: " + onOff(v.poll.hideTotals), func() {
+			v.poll.hideTotals = !v.poll.hideTotals
+		}},
+	}
+	if len(v.poll.options) < v.app.MaxPollOptions() {
+		actions = append(actions, action{"Add an option", func() {
+			v.poll.options = append(v.poll.options, newPollOption(""))
+			v.focus = v.fields() - 1
+		}})
+	}
+	if option := v.focus - v.pollFocus(); option >= 0 && len(v.poll.options) > 2 {
+		actions = append(actions, action{"Remove this option", func() {
+			v.poll.options = slices.Delete(v.poll.options, option, option+1)
+			v.focus = min(v.focus, v.fields()-1)
+		}})
+	}
+	// An edit leaves out the poll the status has when it is unchanged, so
+	// it cannot remove it
+	if v.editing == nil || v.editing.Poll == nil {
+		actions = append(actions, action{"Remove the poll", func() {
+			v.poll = nil
+			v.focus = composeFocusText
+		}})
+	}
+
+	items := make([]MenuItem, len(actions))
+	for i, a := range actions {
+		items[i] = MenuItem{Label: a.label}
+	}
+	v.openMenu("Poll", items, func(index int) {
+		actions[index].run()
+	})
+}
+
+func (v *ComposeView) Draw(win vaxis.Window) {
+	if v.showingMenu {
+		v.menu.Draw(win, true)
+		return
+	}
+
+	width, height := win.Size()
+	boldStyle := vaxis.Style{Attribute: vaxis.AttrBold}
+	dimStyle := vaxis.Style{Attribute: vaxis.AttrDim}
+
+	title := "New post"
+	hints := []string{"Ctrl+S post", "Tab next field", "Ctrl+T visibility", "Ctrl+E editor", "Ctrl+O attach", "Ctrl+P poll", "Esc discard"}
+	if v.editing != nil {
+		title = "Edit post"
+		hints = []string{"Ctrl+S save", "Tab next field", "Ctrl+E editor", "Ctrl+O attach", "Ctrl+P poll", "Esc discard"}
+	} else if v.inReplyTo != nil {
+		title = "Reply to @" + v.inReplyTo.Account.Acct
+	}
+	if v.focusedMedia() >= 0 {
+		hints = append(hints[:len(hints)-1], "Ctrl+G focal point", "Ctrl+X remove", hints[len(hints)-1])
+	}
+	win.New(0, 0, width, 1).PrintTruncate(0, vaxis.Segment{Text: title, Style: boldStyle})
+	win.New(0, 1, width, 1).PrintTruncate(0, vaxis.Segment{
+		Text:  strings.Join(hints, " · "),
+		Style: dimStyle,
+	})
+
+	// Only the field being edited shows the cursor
+	cwWin := win.New(0, 3, width, 1)
+	if v.focus == composeFocusCW {
+		v.spoiler.Draw(cwWin)
+	} else if cw := v.spoiler.String(); cw != "" {
+		cwWin.PrintTruncate(0, vaxis.Segment{Text: "CW: ", Style: boldStyle}, vaxis.Segment{Text: cw})
+	} else {
+		cwWin.PrintTruncate(0, vaxis.Segment{Text: "No content warning", Style: dimStyle})
+	}
+
+	mediaRows := 0
+	if len(v.media) > 0 {
+		mediaRows = len(v.media)*mediaRowHeight() + 2
+	}
+	pollRows := 0
+	if v.poll != nil {
+		pollRows = len(v.poll.options) + 2
+	}
+	textHeight := max(height-8-mediaRows-pollRows, 1)
+	v.text.Draw(win.New(0, 5, width, textHeight), v.focus == composeFocusText)
+
+	y := 5 + textHeight + 1
+	if len(v.media) > 0 {
+		v.drawMedia(win, y)
+		y += mediaRows
+	}
+
+	if v.poll != nil {
+		choice := "single choice"
+		if v.poll.multiple {
+			choice = "multiple choice"
+		}
+		win.New(0, y, width, 1).PrintTruncate(0,
+			vaxis.Segment{Text: "Poll", Style: boldStyle},
+			vaxis.Segment{Text: " · " + v.poll.duration.label + " · " + choice, Style: dimStyle},
+		)
+		for i, option := range v.poll.options {
+			optionWin := win.New(0, y+1+i, width, 1)
+			label := vaxis.Segment{Text: fmt.Sprintf("%d. ", i+1)}
+			if v.focus == v.pollFocus()+i {
+				optionWin.Print(label)
+				option.Draw(win.New(3, y+1+i, width-3, 1))
+			} else if text := option.String(); text != "" {
+				optionWin.PrintTruncate(0, label, vaxis.Segment{Text: text})
+			} else {
+				optionWin.PrintTruncate(0, label, vaxis.Segment{Text: "Option", Style: dimStyle})
+			}
+		}
+	}
+
+	settings := []string{visibilityLabel(v.visibility)}
+	if v.language != "" {
+		settings = append(settings, v.language)
+	}
+	if v.sensitive {
+		settings = append(settings, "Sensitive")
+	}
+	status := fmt.Sprintf("%s · %d", strings.Join(settings, " · "), v.remaining())
+	statusStyle := dimStyle
+	if v.remaining() < 0 {
+		statusStyle = vaxis.Style{Foreground: vaxis.IndexColor(1)}
+	}
+	if v.posting && v.editing != nil {
+		status = "Saving..."
+	} else if v.posting {
+		status = "Posting..."
+	}
+	win.New(0, height-2, width, 1).PrintTruncate(0, vaxis.Segment{Text: status, Style: statusStyle})
+}
+
+func (v *ComposeView) HandleKey(key vaxis.Key) {
+	if v.showingMenu {
+		switch v.menu.HandleKey(key) {
+		case "select":
+			v.showingMenu = false
+			v.onMenuSelect(v.menu.Selected())
+		case "close":
+			v.showingMenu = false
+		}
+		return
+	}
+	if v.posting {
+		return
+	}
+	typed := key.EventType != vaxis.EventPaste
+
+	switch {
+	case key.Matches('s', vaxis.ModCtrl):
+		if strings.TrimSpace(v.text.String()) == "" && len(v.media) == 0 {
+			v.app.footer.SetText("Nothing to post")
+			return
+		}
+		if v.remaining() < 0 {
+			v.app.footer.SetText("The post is too long")
+			return
+		}
+		poll, problem := v.tootPoll()
+		if problem == "" && v.editing != nil {
+			poll, problem = v.editedPoll(poll)
+		}
+		if problem == "" {
+			problem = v.mediaProblem()
+		}
+		if problem == "" && v.loadingReply != "" {
+			problem = "Wait for the status to reply to"
+		}
+		if problem != "" {
+			v.app.footer.SetText(problem)
+			return
+		}
+		if count := v.undescribedMedia(); count > 0 && v.app.config.Compose.AltText == config.AltTextWarn {
+			v.app.ShowConfirm(fmt.Sprintf("Post %d attachments without a description?", count), func() {
+				v.submit(poll)
+			})
+			return
+		}
+		v.submit(poll)
+	case key.Matches('t', vaxis.ModCtrl) && v.editing == nil:
+		next := (slices.Index(visibilities, v.visibility) + 1) % len(visibilities)
+		v.visibility = visibilities[next]
+	case key.Matches('p', vaxis.ModCtrl):
+		v.editPoll()
+	case key.Matches('e', vaxis.ModCtrl):
+		v.editExternally()
+	case key.Matches('o', vaxis.ModCtrl):
+		v.attachMedia()
+	case key.Matches('g', vaxis.ModCtrl):
+		v.editFocalPoint()
+	case key.Matches('x', vaxis.ModCtrl):
+		v.removeMedia()
+	case key.Matches(vaxis.KeyTab) && typed:
+		v.focus = (v.focus + 1) % v.fields()
+	case key.Matches(vaxis.KeyTab, vaxis.ModShift) && typed:
+		v.focus = (v.focus + v.fields() - 1) % v.fields()
+	case key.Matches(vaxis.KeyEsc):
+		if strings.TrimSpace(v.text.String()) == "" && v.poll == nil && len(v.media) == 0 && v.editing == nil {
+			v.close()
+			return
+		}
+		question := "Discard this draft?"
+		if v.editing != nil {
+			question = "Discard the changes?"
+		}
+		v.openMenu(question, []MenuItem{{Label: "Discard"}, {Label: "Keep editing"}}, func(index int) {
+			if index == 0 {
+				v.close()
+			}
+		})
+	case v.focus == composeFocusCW:
+		if key.Matches(vaxis.KeyEnter) && typed {
+			v.focus = composeFocusText
+			return
+		}
+		v.spoiler.Update(key)
+	case v.focusedMedia() >= 0:
+		if key.Matches(vaxis.KeyEnter) && typed {
+			v.focus = min(v.focus+1, v.fields()-1)
+			return
+		}
+		v.media[v.focusedMedia()].description.Update(key)
+	case v.focusedOption() != nil:
+		if key.Matches(vaxis.KeyEnter) && typed {
+			// Enter on the last option adds another while the server allows
+			if v.focus == v.fields()-1 && len(v.poll.options) < v.app.MaxPollOptions() {
+				v.poll.options = append(v.poll.options, newPollOption(""))
+			}
+			v.focus = min(v.focus+1, v.fields()-1)
+			return
+		}
+		v.focusedOption().Update(key)
+	default:
+		v.text.HandleKey(key)
+	}
+}
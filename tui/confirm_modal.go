@@ -0,0 +1,64 @@
+package tui
+
+import (
+	"git.sr.ht/~rockorager/vaxis"
+	"git.sr.ht/~rockorager/vaxis/widgets/border"
+	"github.com/rivo/uniseg"
+)
+
+// ConfirmModal asks a yes or no question over the whole screen
+type ConfirmModal struct {
+	message   string
+	onConfirm func()
+}
+
+func CreateConfirmModal(message string, onConfirm func()) *ConfirmModal {
+	return &ConfirmModal{
+		message:   message,
+		onConfirm: onConfirm,
+	}
+}
+
+func (m *ConfirmModal) Draw(win vaxis.Window) {
+	width, height := win.Size()
+
+	hint := "y yes · n no"
+	modalWidth := min(max(40, uniseg.StringWidth(m.message)+4), width)
+	modalHeight := 5
+	x := (width - modalWidth) / 2
+	y := (height - modalHeight) / 2
+
+	modalWin := win.New(x, y, modalWidth, modalHeight)
+	modalWin.Fill(vaxis.Cell{
+		Character: vaxis.Character{Grapheme: " ", Width: 1},
+	})
+	modalWin = border.All(modalWin, vaxis.Style{
+		Foreground: vaxis.IndexColor(4),
+		Attribute:  vaxis.AttrBold,
+	})
+	modalWin.New(1, 0, modalWidth-4, 1).PrintTruncate(0,
+		vaxis.Segment{
+			Text: m.message,
+			Style: vaxis.Style{
+				Attribute: vaxis.AttrBold,
+			},
+		},
+	)
+	modalWin.New(1, 2, modalWidth-4, 1).PrintTruncate(0,
+		vaxis.Segment{
+			Text: hint,
+			Style: vaxis.Style{
+				Attribute: vaxis.AttrDim,
+			},
+		},
+	)
+}
+
+func (m *ConfirmModal) HandleKey(key vaxis.Key) string {
+	if key.Matches('y') || key.Matches(vaxis.KeyEnter) {
+		return "confirm"
+	} else if key.Matches('n') || key.Matches(vaxis.KeyEsc) || key.Matches('q') {
+		return "close"
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package tui
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/AbeEstrada/tuit/utils"
+)
+
+// Returns the command of the editor set in $VISUAL or $EDITOR, vi otherwise
+func editorCommand() string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.TrimSpace(os.Getenv(name)); editor != "" {
+			return editor
+		}
+	}
+	return "vi"
+}
+
+// Opens text in the external editor and returns it as saved. The file name
+// ends with suffix, for editors that pick a mode from the extension
+func (app *App) EditExternally(text, suffix string) (string, error) {
+	args, err := utils.SplitCommand(editorCommand())
+	if err != nil {
+		return "", err
+	}
+	if len(args) == 0 {
+		return "", errors.New("empty editor command")
+	}
+
+	file, err := os.CreateTemp("", "tuit-*"+suffix)
+	if err != nil {
+		return "", err
+	}
+	path := file.Name()
+	defer os.Remove(path)
+
+	_, err = file.WriteString(text)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	if err := app.runSuspended(cmd); err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,316 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"git.sr.ht/~rockorager/vaxis"
+	"github.com/AbeEstrada/tuit/api"
+	"github.com/mattn/go-mastodon"
+)
+
+type filterExpiry struct {
+	label    string
+	duration time.Duration
+}
+
+var filterExpiries = []filterExpiry{
+	{"Never", 0},
+	{"30 minutes", 30 * time.Minute},
+	{"1 hour", time.Hour},
+	{"6 hours", 6 * time.Hour},
+	{"12 hours", 12 * time.Hour},
+	{"1 day", 24 * time.Hour},
+	{"1 week", 7 * 24 * time.Hour},
+}
+
+// Returns how long until the filter expires, zero when it never does
+func filterExpiresIn(filter *api.Filter) time.Duration {
+	if filter.ExpiresAt == nil || filter.ExpiresAt.IsZero() {
+		return 0
+	}
+	// An expired filter keeps matching nothing until it is given a new expiry
+	return max(time.Until(*filter.ExpiresAt), time.Second)
+}
+
+// FilterView shows the settings and keywords of a server filter and edits them
+type FilterView struct {
+	app          *App
+	filter       *api.Filter
+	selected     int
+	scrollOffset int
+	menu         *MenuView
+	showingMenu  bool
+	onMenuSelect func(index int)
+	onUpdate     func(filter *api.Filter)
+	onDelete     func(id mastodon.ID)
+}
+
+func CreateFilterView() *FilterView {
+	return &FilterView{
+		menu: CreateMenuView(),
+	}
+}
+
+func (v *FilterView) SetApp(app *App) {
+	v.app = app
+}
+
+// Saves the filter with the changes made by fn. expiresIn is the new expiry,
+// or nil to keep the current one
+func (v *FilterView) update(filter *api.Filter, expiresIn *time.Duration, fn func(filter *api.Filter)) {
+	changed := *filter
+	changed.Context = slices.Clone(filter.Context)
+	fn(&changed)
+	expiry := filterExpiresIn(filter)
+	if expiresIn != nil {
+		expiry = *expiresIn
+	}
+	updated, err := v.app.customClient.UpdateFilter(context.Background(), &changed, expiry)
+	if err != nil {
+		log.Printf("Failed to update filter: %v", err)
+		v.app.footer.SetText("Failed to update filter")
+	} else if v.onUpdate != nil {
+		v.onUpdate(updated)
+	}
+	v.app.vx.PostEvent(vaxis.Redraw{})
+}
+
+func (v *FilterView) delete(filter *api.Filter) {
+	if err := v.app.customClient.DeleteFilter(context.Background(), filter.ID); err != nil {
+		log.Printf("Failed to delete filter: %v", err)
+		v.app.footer.SetText("Failed to delete filter")
+	} else {
+		v.app.footer.SetText("Deleted " + filter.Title)
+		if v.onDelete != nil {
+			v.onDelete(filter.ID)
+		}
+	}
+	v.app.vx.PostEvent(vaxis.Redraw{})
+}
+
+// Runs a change to the keywords of the filter, then reloads the filter
+func (v *FilterView) updateKeywords(filter *api.Filter, action string, fn func(ctx context.Context) error) {
+	ctx := context.Background()
+	if err := fn(ctx); err != nil {
+		log.Printf("Failed to %s: %v", action, err)
+		v.app.footer.SetText("Failed to " + action)
+		v.app.vx.PostEvent(vaxis.Redraw{})
+		return
+	}
+
+	filters, err := v.app.customClient.GetFilters(ctx)
+	if err != nil {
+		log.Printf("Failed to fetch filters: %v", err)
+		return
+	}
+	for _, f := range filters {
+		if f.ID == filter.ID && v.onUpdate != nil {
+			v.onUpdate(f)
+		}
+	}
+	v.app.vx.PostEvent(vaxis.Redraw{})
+}
+
+func filterActionLabel(action string) string {
+	if action == api.FilterActionHide {
+		return "Hide completely"
+	}
+	return "Hide with a warning"
+}
+
+func (v *FilterView) Draw(win vaxis.Window, focused bool, filter *api.Filter) {
+	if filter == nil {
+		win.Println(0, vaxis.Segment{Text: ""})
+		return
+	}
+
+	if v.filter == nil || v.filter.ID != filter.ID {
+		v.showingMenu = false
+		v.selected = 0
+		v.scrollOffset = 0
+	}
+	v.filter = filter
+
+	if v.showingMenu {
+		v.menu.Draw(win, focused)
+		return
+	}
+
+	width, height := win.Size()
+	headingStyle := vaxis.Style{Attribute: vaxis.AttrBold}
+	dimStyle := vaxis.Style{Attribute: vaxis.AttrDim}
+
+	expiry := "Never expires"
+	if filter.ExpiresAt != nil && !filter.ExpiresAt.IsZero() {
+		if filter.Expired(time.Now()) {
+			expiry = "Expired"
+		} else {
+			expiry = "Expires " + filter.ExpiresAt.Local().Format("Jan 2, 2006 15:04")
+		}
+	}
+
+	win.New(0, 0, width, 1).PrintTruncate(0, vaxis.Segment{Text: filter.Title, Style: headingStyle})
+	win.New(0, 2, width, 1).PrintTruncate(0, vaxis.Segment{Text: filterActionLabel(filter.FilterAction)})
+	win.New(0, 3, width, 1).PrintTruncate(0, vaxis.Segment{Text: "In " + strings.Join(filter.Context, ", ")})
+	win.New(0, 4, width, 1).PrintTruncate(0, vaxis.Segment{Text: expiry})
+
+	win.Println(6, vaxis.Segment{Text: fmt.Sprintf("Keywords (%d)", len(filter.Keywords)), Style: headingStyle})
+	v.selected = min(v.selected, max(len(filter.Keywords)-1, 0))
+
+	rows := height - 10
+	if v.selected < v.scrollOffset {
+		v.scrollOffset = v.selected
+	} else if rows > 0 && v.selected >= v.scrollOffset+rows {
+		v.scrollOffset = v.selected - rows + 1
+	}
+	for i := v.scrollOffset; i < len(filter.Keywords) && i-v.scrollOffset < rows; i++ {
+		keyword := filter.Keywords[i]
+		var attr vaxis.AttributeMask
+		if i == v.selected && focused {
+			attr = vaxis.AttrReverse
+		}
+		segments := []vaxis.Segment{{Text: " " + keyword.Keyword, Style: vaxis.Style{Attribute: attr}}}
+		if keyword.WholeWord {
+			segments = append(segments, vaxis.Segment{Text: " · whole word", Style: vaxis.Style{Attribute: attr | vaxis.AttrDim}})
+		}
+		win.New(0, 7+i-v.scrollOffset, width, 1).PrintTruncate(0, segments...)
+	}
+
+	if focused {
+		win.New(0, height-2, width, 1).PrintTruncate(0, vaxis.Segment{
+			Text:  "r rename · A action · c contexts · e expiry · a add · w whole word · d remove · D delete filter",
+			Style: dimStyle,
+		})
+	}
+}
+
+func (v *FilterView) openMenu(title string, items []MenuItem, toggles bool, onSelect func(index int)) {
+	v.menu.SetItems(title, items, toggles)
+	v.onMenuSelect = onSelect
+	v.showingMenu = true
+}
+
+func (v *FilterView) HandleKey(key vaxis.Key) {
+	if v.showingMenu {
+		switch v.menu.HandleKey(key) {
+		case "select":
+			if !v.menu.toggles {
+				v.showingMenu = false
+			}
+			v.onMenuSelect(v.menu.Selected())
+		case "close":
+			v.showingMenu = false
+		}
+		return
+	}
+
+	filter := v.filter
+	if filter == nil {
+		return
+	}
+	client := v.app.customClient
+
+	switch {
+	case key.Matches('j'), key.Matches(vaxis.KeyDown):
+		if v.selected < len(filter.Keywords)-1 {
+			v.selected++
+		}
+	case key.Matches('k'), key.Matches(vaxis.KeyUp):
+		if v.selected > 0 {
+			v.selected--
+		}
+	case key.Matches('r'):
+		v.app.ShowPrompt("Rename filter", filter.Title, func(title string) {
+			if title == "" || title == filter.Title {
+				return
+			}
+			go v.update(filter, nil, func(filter *api.Filter) {
+				filter.Title = title
+			})
+		})
+	case key.Matches('A'):
+		go v.update(filter, nil, func(filter *api.Filter) {
+			if filter.FilterAction == api.FilterActionHide {
+				filter.FilterAction = api.FilterActionWarn
+			} else {
+				filter.FilterAction = api.FilterActionHide
+			}
+		})
+	case key.Matches('c'):
+		items := make([]MenuItem, len(api.FilterContexts))
+		for i, c := range api.FilterContexts {
+			items[i] = MenuItem{Label: c, Checked: slices.Contains(filter.Context, c)}
+		}
+		v.openMenu("Filter in", items, true, func(index int) {
+			checked := !v.menu.items[index].Checked
+			count := 0
+			for _, item := range v.menu.items {
+				if item.Checked {
+					count++
+				}
+			}
+			if !checked && count == 1 {
+				v.app.footer.SetText("A filter needs at least one context")
+				return
+			}
+			v.menu.SetChecked(index, checked)
+			// Saved from the menu, which holds the earlier toggles too
+			var contexts []string
+			for i, item := range v.menu.items {
+				if item.Checked {
+					contexts = append(contexts, api.FilterContexts[i])
+				}
+			}
+			go v.update(filter, nil, func(filter *api.Filter) {
+				filter.Context = contexts
+			})
+		})
+	case key.Matches('e'):
+		items := make([]MenuItem, len(filterExpiries))
+		for i, e := range filterExpiries {
+			items[i] = MenuItem{Label: e.label}
+		}
+		v.openMenu("Expire after", items, false, func(index int) {
+			expiresIn := filterExpiries[index].duration
+			go v.update(filter, &expiresIn, func(filter *api.Filter) {})
+		})
+	case key.Matches('a'):
+		v.app.ShowPrompt("Add keyword", "", func(keyword string) {
+			if keyword == "" {
+				return
+			}
+			go v.updateKeywords(filter, "add keyword", func(ctx context.Context) error {
+				_, err := client.AddFilterKeyword(ctx, filter.ID, keyword, true)
+				return err
+			})
+		})
+	case key.Matches('w'):
+		if v.selected < len(filter.Keywords) {
+			keyword := *filter.Keywords[v.selected]
+			keyword.WholeWord = !keyword.WholeWord
+			go v.updateKeywords(filter, "update keyword", func(ctx context.Context) error {
+				_, err := client.UpdateFilterKeyword(ctx, &keyword)
+				return err
+			})
+		}
+	case key.Matches('d'):
+		if v.selected < len(filter.Keywords) {
+			id := filter.Keywords[v.selected].ID
+			go v.updateKeywords(filter, "remove keyword", func(ctx context.Context) error {
+				return client.DeleteFilterKeyword(ctx, id)
+			})
+		}
+	case key.Matches('D'):
+		items := []MenuItem{{Label: "Delete"}, {Label: "Cancel"}}
+		v.openMenu("Delete "+filter.Title+"?", items, false, func(index int) {
+			if index == 0 {
+				go v.delete(filter)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package tui
+
+import (
+	"slices"
+	"time"
+
+	"github.com/AbeEstrada/tuit/api"
+	"github.com/mattn/go-mastodon"
+)
+
+// Returns the strongest action of the server filters that match the status in
+// the context, hide before warn, and the title of that filter. The action is
+// empty when no filter matches
+func matchFilters(status *mastodon.Status, context string) (action string, title string) {
+	results := status.Filtered
+	if status.Reblog != nil {
+		results = append(slices.Clone(status.Reblog.Filtered), results...)
+	}
+
+	now := time.Now()
+	for _, result := range results {
+		filter := result.Filter
+		if !slices.Contains(filter.Context, context) {
+			continue
+		}
+		if !filter.ExpiresAt.IsZero() && filter.ExpiresAt.Before(now) {
+			continue
+		}
+		if filter.FilterAction == api.FilterActionHide {
+			return api.FilterActionHide, filter.Title
+		}
+		if action == "" {
+			action, title = api.FilterActionWarn, filter.Title
+		}
+	}
+	return action, title
+}
+
+func filterAction(status *mastodon.Status, context string) string {
+	action, _ := matchFilters(status, context)
+	return action
+}
+
+// Returns the title of the filter that collapses a status in the current
+// timeline, or empty when the status is shown in full
+func (v *TimelineView) filterWarning(status *mastodon.Status) string {
+	if status == nil || v.filterRevealed[status.ID] || v.index >= len(v.timelines) {
+		return ""
+	}
+	action, title := matchFilters(status, v.timelines[v.index].filterContext())
+	if action != api.FilterActionWarn {
+		return ""
+	}
+	return title
+}
+
+// Shows a status collapsed by a filter in full
+func (v *TimelineView) RevealFiltered(status *mastodon.Status) {
+	v.filterRevealed[status.ID] = true
+}
@@ -0,0 +1,80 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AbeEstrada/tuit/api"
+	"github.com/mattn/go-mastodon"
+)
+
+func filterResult(title, action string, expiresAt time.Time, contexts ...string) mastodon.FilterResult {
+	var result mastodon.FilterResult
+	result.Filter.Title = title
+	result.Filter.FilterAction = action
+	result.Filter.ExpiresAt = expiresAt
+	result.Filter.Context = contexts
+	return result
+}
+
+func TestMatchFilters(t *testing.T) {
+	home := api.FilterContextHome
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		status    *mastodon.Status
+		wantAct   string
+		wantTitle string
+	}{
+		{"no filters", &mastodon.Status{}, "", ""},
+		{
+			"warn",
+			&mastodon.Status{Filtered: []mastodon.FilterResult{filterResult("spoilers", api.FilterActionWarn, time.Time{}, home)}},
+			api.FilterActionWarn, "spoilers",
+		},
+		{
+			"other context",
+			&mastodon.Status{Filtered: []mastodon.FilterResult{filterResult("spoilers", api.FilterActionWarn, time.Time{}, api.FilterContextPublic)}},
+			"", "",
+		},
+		{
+			"hide over an earlier warn",
+			&mastodon.Status{Filtered: []mastodon.FilterResult{
+				filterResult("spoilers", api.FilterActionWarn, time.Time{}, home),
+				filterResult("ads", api.FilterActionHide, time.Time{}, home),
+			}},
+			api.FilterActionHide, "ads",
+		},
+		{
+			"first warn keeps its title",
+			&mastodon.Status{Filtered: []mastodon.FilterResult{
+				filterResult("spoilers", api.FilterActionWarn, time.Time{}, home),
+				filterResult("sports", api.FilterActionWarn, time.Time{}, home),
+			}},
+			api.FilterActionWarn, "spoilers",
+		},
+		{
+			"expired filter skipped",
+			&mastodon.Status{Filtered: []mastodon.FilterResult{
+				filterResult("ads", api.FilterActionHide, past, home),
+				filterResult("spoilers", api.FilterActionWarn, future, home),
+			}},
+			api.FilterActionWarn, "spoilers",
+		},
+		{
+			"filter on a boosted status",
+			&mastodon.Status{Reblog: &mastodon.Status{Filtered: []mastodon.FilterResult{filterResult("ads", api.FilterActionHide, time.Time{}, home)}}},
+			api.FilterActionHide, "ads",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, title := matchFilters(tt.status, home)
+			if action != tt.wantAct || title != tt.wantTitle {
+				t.Errorf("matchFilters = (%q, %q), want (%q, %q)", action, title, tt.wantAct, tt.wantTitle)
+			}
+		})
+	}
+}
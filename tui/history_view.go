@@ -0,0 +1,197 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"git.sr.ht/~rockorager/vaxis"
+	"github.com/AbeEstrada/tuit/utils"
+	"github.com/mattn/go-mastodon"
+)
+
+// HistoryView shows the revisions of an edited status, newest first, each
+// compared word by word with the one before it
+type HistoryView struct {
+	app          *App
+	statusID     mastodon.ID
+	revisions    []*mastodon.StatusHistory
+	loading      bool
+	scrollOffset int
+	totalHeight  int
+	viewHeight   int
+}
+
+func CreateHistoryView() *HistoryView {
+	return &HistoryView{}
+}
+
+func (v *HistoryView) SetApp(app *App) {
+	v.app = app
+}
+
+func (v *HistoryView) Open(status *mastodon.Status) {
+	v.statusID = status.ID
+	v.revisions = nil
+	v.loading = true
+	v.scrollOffset = 0
+	go v.fetchHistory(status.ID)
+}
+
+func (v *HistoryView) fetchHistory(id mastodon.ID) {
+	revisions, err := v.app.client.GetStatusHistory(context.Background(), id)
+	if id != v.statusID {
+		return
+	}
+	v.loading = false
+	if err != nil {
+		log.Printf("Failed to fetch edit history: %v", err)
+		v.app.footer.SetText("Failed to load the edit history")
+	} else {
+		v.revisions = revisions
+	}
+	v.app.vx.PostEvent(vaxis.Redraw{})
+}
+
+// Returns the plain text of a revision's content
+func revisionText(revision *mastodon.StatusHistory) string {
+	var text strings.Builder
+	for _, seg := range utils.ParseStatus(revision.Content, nil) {
+		text.WriteString(seg.Text)
+	}
+	return text.String()
+}
+
+// Returns the segments showing the changes from old to new
+func diffSegments(old, new string, style vaxis.Style) []vaxis.Segment {
+	deleted := style
+	deleted.Foreground = vaxis.IndexColor(1)
+	deleted.Attribute |= vaxis.AttrStrikethrough
+	inserted := style
+	inserted.Foreground = vaxis.IndexColor(2)
+
+	var segs []vaxis.Segment
+	for _, op := range utils.DiffWords(old, new) {
+		switch op.Kind {
+		case utils.DiffDelete:
+			segs = append(segs, vaxis.Segment{Text: op.Text, Style: deleted})
+		case utils.DiffInsert:
+			segs = append(segs, vaxis.Segment{Text: op.Text, Style: inserted})
+		default:
+			segs = append(segs, vaxis.Segment{Text: op.Text, Style: style})
+		}
+	}
+	return segs
+}
+
+// Returns the notes on what changed besides the text
+func revisionNotes(revision, previous *mastodon.StatusHistory) []string {
+	var notes []string
+	media := len(revision.MediaAttachments)
+	if previous != nil && media != len(previous.MediaAttachments) {
+		notes = append(notes, fmt.Sprintf("Media: %d, was %d", media, len(previous.MediaAttachments)))
+	} else if media > 0 {
+		notes = append(notes, fmt.Sprintf("Media: %d", media))
+	}
+	if previous != nil && revision.Sensitive != previous.Sensitive {
+		if revision.Sensitive {
+			notes = append(notes, "Marked sensitive")
+		} else {
+			notes = append(notes, "No longer sensitive")
+		}
+	}
+	return notes
+}
+
+func (v *HistoryView) Draw(win vaxis.Window) {
+	width, height := win.Size()
+	boldStyle := vaxis.Style{Attribute: vaxis.AttrBold}
+	dimStyle := vaxis.Style{Attribute: vaxis.AttrDim}
+
+	win.New(0, 0, width, 1).PrintTruncate(0, vaxis.Segment{Text: "Edit history", Style: boldStyle})
+	win.New(0, 1, width, 1).PrintTruncate(0, vaxis.Segment{Text: "j/k scroll · q close", Style: dimStyle})
+
+	if v.loading {
+		win.Println(3, vaxis.Segment{Text: "Loading..."})
+		v.totalHeight = 0
+		return
+	}
+	if len(v.revisions) == 0 {
+		win.Println(3, vaxis.Segment{Text: "No edits to show", Style: dimStyle})
+		v.totalHeight = 0
+		return
+	}
+
+	height = max(height-4, 0)
+	win = win.New(0, 3, width, height)
+	v.viewHeight = height
+	so := v.scrollOffset
+
+	// Measures and draws wrapped segments, returning the rows they take
+	wrap := func(y int, emojis map[string]string, segs ...vaxis.Segment) int {
+		_, rows := wrapText(win.New(0, -height*4, width, height*4), emojis, segs...)
+		wrapText(win.New(0, y-so, width, height*4), emojis, segs...)
+		return rows
+	}
+
+	y := 0
+	for i := len(v.revisions) - 1; i >= 0; i-- {
+		revision := v.revisions[i]
+		var previous *mastodon.StatusHistory
+		if i > 0 {
+			previous = v.revisions[i-1]
+		}
+		emojis := v.app.Emojis(revision.Emojis, revision.Account.Emojis)
+
+		label := "Edited"
+		if i == 0 {
+			label = "Original"
+		} else if i == len(v.revisions)-1 {
+			label = "Current"
+		}
+		win.Println(y-so, vaxis.Segment{
+			Text:  fmt.Sprintf("%s · %s", label, utils.FormatTimeSince(revision.CreatedAt.Local())),
+			Style: boldStyle,
+		})
+		y += 1
+
+		// The original has nothing to compare with, so shows no changes
+		oldText, oldSpoiler := revisionText(revision), revision.SpoilerText
+		if previous != nil {
+			oldText, oldSpoiler = revisionText(previous), previous.SpoilerText
+		}
+		if revision.SpoilerText != "" || oldSpoiler != "" {
+			segs := append([]vaxis.Segment{{Text: "⚠ ", Style: boldStyle}}, diffSegments(oldSpoiler, revision.SpoilerText, boldStyle)...)
+			y += wrap(y, emojis, segs...)
+		}
+		y += wrap(y, emojis, diffSegments(oldText, revisionText(revision), vaxis.Style{})...)
+
+		for _, note := range revisionNotes(revision, previous) {
+			win.Println(y-so, vaxis.Segment{Text: note, Style: dimStyle})
+			y += 1
+		}
+		y += 1
+	}
+	v.totalHeight = y
+}
+
+func (v *HistoryView) HandleKey(key vaxis.Key) string {
+	switch {
+	case key.Matches('q'), key.Matches(vaxis.KeyEsc):
+		return "close"
+	case key.Matches('j'):
+		if v.scrollOffset < v.totalHeight-v.viewHeight {
+			v.scrollOffset++
+		}
+	case key.Matches('k'):
+		if v.scrollOffset > 0 {
+			v.scrollOffset--
+		}
+	case key.Matches('g'):
+		v.scrollOffset = 0
+	case key.Matches('G'):
+		v.scrollOffset = max(v.totalHeight-v.viewHeight, 0)
+	}
+	return ""
+}
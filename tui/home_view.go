@@ -4,8 +4,10 @@ import (
 	"context"
 	"log"
 	"sort"
+	"strings"
 
 	"git.sr.ht/~rockorager/vaxis"
+	"github.com/AbeEstrada/tuit/api"
 	"github.com/AbeEstrada/tuit/utils"
 	"github.com/mattn/go-mastodon"
 )
@@ -15,10 +17,18 @@ type HomeView struct {
 	timeline       *TimelineView
 	statusView     *StatusView
 	accountView    *AccountView
+	listView       *ListView
+	filterView     *FilterView
 	linksView      *LinksView
+	mediaView      *MediaView
+	composeView    *ComposeView
+	historyView    *HistoryView
 	focusedView    int
 	isStreaming    bool
 	showingLinks   bool
+	showingMedia   bool
+	showingCompose bool
+	showingHistory bool
 	lastSelectedID mastodon.ID
 }
 
@@ -26,12 +36,54 @@ func CreateHomeView() *HomeView {
 	v := &HomeView{
 		statusView:  CreateStatusView(),
 		accountView: CreateAccountView(),
+		listView:    CreateListView(),
+		filterView:  CreateFilterView(),
 		linksView:   CreateLinksView(),
+		mediaView:   CreateMediaView(),
+		composeView: CreateComposeView(),
+		historyView: CreateHistoryView(),
 		focusedView: 0,
 	}
 	timelineView := CreateTimelineView()
 	timelineView.onLoadMore = v.loadMoreTimeline
 	v.timeline = timelineView
+	v.accountView.onSelectTab = func(tab AccountTab) {
+		if !v.app.loading {
+			go v.loadAccountTab(tab)
+		}
+	}
+	v.accountView.onOpenList = func(account *mastodon.Account, list AccountList) {
+		if !v.app.loading {
+			go v.openAccountList(account, list)
+		}
+	}
+	v.listView.onOpen = func(list *api.List) {
+		if !v.app.loading {
+			go v.openList(list)
+		}
+	}
+	v.listView.onUpdate = v.updateList
+	v.listView.onDelete = func(id mastodon.ID) {
+		if index := v.listsIndex(); index >= 0 {
+			v.timeline.DeleteFromTimeline(index, id)
+		}
+	}
+	v.filterView.onUpdate = func(filter *api.Filter) {
+		if index := v.filtersIndex(); index >= 0 {
+			v.timeline.UpdateEdit(index, FilterItem{Filter: filter})
+		}
+	}
+	v.filterView.onDelete = func(id mastodon.ID) {
+		if index := v.filtersIndex(); index >= 0 {
+			v.timeline.DeleteFromTimeline(index, id)
+		}
+	}
+	v.statusView.onUpdate = v.timeline.UpdateStatus
+	v.composeView.onPost = v.addReply
+	v.composeView.onEdit = v.timeline.UpdateStatus
+	v.composeView.onClose = func() {
+		v.showingCompose = false
+	}
 
 	return v
 }
@@ -41,6 +93,11 @@ func (v *HomeView) SetApp(app *App) {
 	v.timeline.SetApp(app)
 	v.statusView.SetApp(app)
 	v.accountView.SetApp(app)
+	v.listView.SetApp(app)
+	v.filterView.SetApp(app)
+	v.composeView.SetApp(app)
+	v.historyView.SetApp(app)
+	app.onFollowsChanged = v.timeline.RefreshMuted
 }
 
 func (v *HomeView) OnActivate() {
@@ -58,7 +115,7 @@ func (v *HomeView) getHomeTimeline() {
 		for i, s := range statuses {
 			items[i] = StatusItem{Status: s}
 		}
-		v.timeline.AddTimeline(items, nil, nil)
+		v.timeline.AddTimeline(TimelineHome, items, nil, nil)
 		v.app.vx.PostEvent(vaxis.Redraw{})
 	}
 
@@ -79,6 +136,11 @@ func (v *HomeView) getStatusContext() {
 		original = original.Reblog
 	}
 
+	v.openThread(original)
+}
+
+// Pushes the thread of a status with the status selected
+func (v *HomeView) openThread(original *mastodon.Status) {
 	v.app.SetLoading(true)
 
 	if original.ID != "" {
@@ -97,8 +159,9 @@ func (v *HomeView) getStatusContext() {
 				items = append(items, StatusItem{Status: status})
 			}
 
-			v.timeline.AddTimeline(items, StatusItem{Status: original}, nil)
-			v.app.vx.PostEvent(vaxis.Redraw{})
+			v.app.RunOnMain(func() {
+				v.timeline.AddTimeline(TimelineThread, items, StatusItem{Status: original}, nil)
+			})
 		}
 	}
 
@@ -114,18 +177,9 @@ func (v *HomeView) reloadHomeTimeline() {
 		return
 	}
 
-	timeline := &v.timeline.timelines[index]
-	items := timeline.Items
-
-	if len(items) == 0 {
-		v.app.SetLoading(false)
-		return
-	}
-
-	var sinceID mastodon.ID
-	if firstItem, ok := items[0].(StatusItem); ok && firstItem.Status != nil {
-		sinceID = firstItem.Status.ID
-	} else {
+	// Paginated by the pages as loaded, whatever filters and mute rules hide
+	sinceID := v.timeline.timelines[index].newestID
+	if sinceID == "" {
 		v.app.SetLoading(false)
 		return
 	}
@@ -156,17 +210,30 @@ func (v *HomeView) loadMoreTimeline() {
 	}
 
 	timeline := &v.timeline.timelines[index]
-	items := timeline.Items
+	items := timeline.all
 
 	if len(items) == 0 {
 		v.app.SetLoading(false)
 		return
 	}
 
-	var maxID mastodon.ID
-	if lastItem, ok := items[len(items)-1].(StatusItem); ok && lastItem.Status != nil {
-		maxID = lastItem.Status.ID
-	} else {
+	switch timeline.Kind {
+	case TimelineAccountList:
+		v.loadMoreAccounts(index)
+		v.app.SetLoading(false)
+		return
+	case TimelineConversations:
+		v.loadMoreConversations(index)
+		v.app.SetLoading(false)
+		return
+	case TimelineBookmarks, TimelineFavourites:
+		v.loadMoreSaved(index)
+		v.app.SetLoading(false)
+		return
+	}
+
+	maxID := timeline.oldestID
+	if maxID == "" {
 		v.app.SetLoading(false)
 		return
 	}
@@ -174,13 +241,19 @@ func (v *HomeView) loadMoreTimeline() {
 	var newStatuses []*mastodon.Status
 	var err error
 
-	if index == 0 {
+	switch timeline.Kind {
+	case TimelineHome:
 		newStatuses, err = v.app.client.GetTimelineHome(context.Background(), &mastodon.Pagination{
 			MaxID: maxID,
 			Limit: 20,
 		})
-	} else if timeline.Account != nil {
-		newStatuses, err = v.app.client.GetAccountStatuses(context.Background(), timeline.Account.ID, &mastodon.Pagination{
+	case TimelineList:
+		newStatuses, err = v.app.client.GetTimelineList(context.Background(), timeline.List.ID, &mastodon.Pagination{
+			MaxID: maxID,
+			Limit: 20,
+		})
+	case TimelineAccount:
+		newStatuses, err = v.app.customClient.GetAccountStatusesFiltered(context.Background(), timeline.Account.ID, timeline.AccountTab.Options(), &mastodon.Pagination{
 			MaxID: maxID,
 			Limit: 20,
 		})
@@ -198,20 +271,57 @@ func (v *HomeView) loadMoreTimeline() {
 	v.app.SetLoading(false)
 }
 
-func (v *HomeView) goToAccountTimeline(currentUser bool) {
-	selectedItem := v.timeline.SelectedItem()
+// Loads the next page of a followers or following timeline
+func (v *HomeView) loadMoreAccounts(index int) {
+	timeline := &v.timeline.timelines[index]
+	if timeline.NextMaxID == "" {
+		return
+	}
 
-	item, ok := selectedItem.(StatusItem)
-	if !ok || item.Status == nil {
+	accounts, pg, err := v.fetchAccountList(timeline.Account.ID, timeline.AccountList, timeline.NextMaxID)
+	if err != nil {
+		log.Printf("Failed to fetch %s: %v", strings.ToLower(timeline.AccountList.String()), err)
 		return
 	}
-	original := item.Status
+	timeline.NextMaxID = pg.MaxID
 
-	if original.Reblog != nil {
-		original = original.Reblog
+	if len(accounts) > 0 {
+		newItems := make([]TimelineItem, len(accounts))
+		for i, a := range accounts {
+			newItems[i] = AccountItem{Account: a}
+		}
+		v.timeline.AppendToTimeline(index, newItems)
+		go v.app.FetchRelationships(accountIDs(accounts))
+		v.app.vx.PostEvent(vaxis.Redraw{})
 	}
+}
+
+func (v *HomeView) goToAccountTimeline(currentUser bool) {
+	var accountID mastodon.ID
+	var selected TimelineItem
 
-	if original.ID == "" {
+	switch item := v.timeline.SelectedItem().(type) {
+	case StatusItem:
+		if item.Status == nil {
+			return
+		}
+		original := item.Status
+		if original.Reblog != nil {
+			original = original.Reblog
+		}
+		if original.ID == "" {
+			return
+		}
+		accountID = original.Account.ID
+		selected = StatusItem{Status: original}
+	case AccountItem:
+		// Only accounts listed in a followers or following timeline, the
+		// account of an account timeline is already shown
+		if item.Account == nil || v.timeline.timelines[v.timeline.index].Kind != TimelineAccountList {
+			return
+		}
+		accountID = item.Account.ID
+	default:
 		return
 	}
 
@@ -223,7 +333,7 @@ func (v *HomeView) goToAccountTimeline(currentUser bool) {
 	if currentUser {
 		account, err = v.app.client.GetAccountCurrentUser(context.Background())
 	} else {
-		account, err = v.app.client.GetAccount(context.Background(), original.Account.ID)
+		account, err = v.app.client.GetAccount(context.Background(), accountID)
 	}
 
 	if err == nil {
@@ -237,13 +347,761 @@ func (v *HomeView) goToAccountTimeline(currentUser bool) {
 				items = append(items, StatusItem{Status: s})
 			}
 
-			v.timeline.AddTimeline(items, StatusItem{Status: original}, account)
+			v.timeline.AddTimeline(TimelineAccount, items, selected, account)
+		}
+	}
+
+	v.app.SetLoading(false)
+}
+
+// Pushes a timeline with the followers or the followed accounts of an account
+func (v *HomeView) openAccountList(account *mastodon.Account, list AccountList) {
+	v.app.SetLoading(true)
+
+	accounts, pg, err := v.fetchAccountList(account.ID, list, "")
+	if err == nil {
+		items := make([]TimelineItem, len(accounts))
+		for i, a := range accounts {
+			items[i] = AccountItem{Account: a}
+		}
+		v.app.RunOnMain(func() {
+			v.timeline.PushTimeline(Timeline{
+				Kind:        TimelineAccountList,
+				Items:       items,
+				Account:     account,
+				AccountList: list,
+				NextMaxID:   pg.MaxID,
+			})
+		})
+		go v.app.FetchRelationships(accountIDs(accounts))
+	} else {
+		log.Printf("Failed to fetch %s: %v", strings.ToLower(list.String()), err)
+	}
+
+	v.app.SetLoading(false)
+}
+
+// Returns a page of the followers or the followed accounts of an account. The
+// pagination holds the max ID of the next page, empty after the last one
+func (v *HomeView) fetchAccountList(id mastodon.ID, list AccountList, maxID mastodon.ID) ([]*mastodon.Account, *mastodon.Pagination, error) {
+	pg := &mastodon.Pagination{MaxID: maxID, Limit: 40}
+	var accounts []*mastodon.Account
+	var err error
+	if list == AccountListFollowing {
+		accounts, err = v.app.client.GetAccountFollowing(context.Background(), id, pg)
+	} else {
+		accounts, err = v.app.client.GetAccountFollowers(context.Background(), id, pg)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if pg.MaxID == maxID {
+		// No Link header to the next page
+		pg.MaxID = ""
+	}
+	return accounts, pg, nil
+}
+
+func accountIDs(accounts []*mastodon.Account) []mastodon.ID {
+	ids := make([]mastodon.ID, len(accounts))
+	for i, account := range accounts {
+		ids[i] = account.ID
+	}
+	return ids
+}
+
+// Reloads the current account timeline with the statuses of another tab
+func (v *HomeView) loadAccountTab(tab AccountTab) {
+	index := v.timeline.index
+	if index >= len(v.timeline.timelines) {
+		return
+	}
+	account := v.timeline.timelines[index].Account
+	if v.timeline.timelines[index].Kind != TimelineAccount {
+		return
+	}
+
+	v.app.SetLoading(true)
+
+	statuses, err := v.app.customClient.GetAccountStatusesFiltered(context.Background(), account.ID, tab.Options(), nil)
+	if err == nil {
+		items := make([]TimelineItem, 0, len(statuses)+1)
+		items = append(items, AccountItem{Account: account})
+		for _, s := range statuses {
+			items = append(items, StatusItem{Status: s})
+		}
+		v.timeline.timelines[index].AccountTab = tab
+		v.timeline.SetItems(index, items)
+	} else {
+		log.Printf("Failed to fetch account statuses: %v", err)
+	}
+
+	v.app.SetLoading(false)
+}
+
+// Pushes the timeline of our lists
+func (v *HomeView) openLists() {
+	v.app.SetLoading(true)
+
+	lists, err := v.app.customClient.GetLists(context.Background())
+	if err == nil {
+		items := make([]TimelineItem, len(lists))
+		for i, list := range lists {
+			items[i] = ListItem{List: list}
+		}
+		v.app.RunOnMain(func() {
+			v.timeline.PushTimeline(Timeline{Kind: TimelineLists, Items: items})
+		})
+	} else {
+		log.Printf("Failed to fetch lists: %v", err)
+	}
+
+	v.app.SetLoading(false)
+}
+
+// Returns the index of the open timeline of our lists, or -1 when it is closed
+func (v *HomeView) listsIndex() int {
+	for i := len(v.timeline.timelines) - 1; i >= 0; i-- {
+		if v.timeline.timelines[i].Kind == TimelineLists {
+			return i
+		}
+	}
+	return -1
+}
+
+func (v *HomeView) createList(title string) {
+	list, err := v.app.customClient.CreateList(context.Background(), &api.List{
+		Title:         title,
+		RepliesPolicy: api.RepliesPolicyList,
+	})
+	if err != nil {
+		log.Printf("Failed to create list: %v", err)
+	}
+	v.app.RunOnMain(func() {
+		if err != nil {
+			v.app.footer.SetText("Failed to create list")
+		} else if index := v.listsIndex(); index >= 0 {
+			v.timeline.AppendToTimeline(index, []TimelineItem{ListItem{List: list}})
+		}
+	})
+}
+
+// Replaces a list in the open timelines after it changes
+func (v *HomeView) updateList(list *api.List) {
+	if index := v.listsIndex(); index >= 0 {
+		v.timeline.UpdateEdit(index, ListItem{List: list})
+	}
+	if index := v.timeline.listIndex(list.ID); index >= 0 {
+		v.timeline.timelines[index].List = list
+	}
+	v.timeline.setTitle()
+}
+
+// Pushes the timeline of a list and keeps it updated while it is open
+func (v *HomeView) openList(list *api.List) {
+	v.app.SetLoading(true)
+
+	statuses, err := v.app.client.GetTimelineList(context.Background(), list.ID, nil)
+	if err == nil {
+		items := make([]TimelineItem, len(statuses))
+		for i, s := range statuses {
+			items[i] = StatusItem{Status: s}
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		v.app.RunOnMain(func() {
+			v.timeline.PushTimeline(Timeline{Kind: TimelineList, Items: items, List: list, cancel: cancel})
+		})
+		go v.streamList(ctx, list.ID)
+	} else {
+		log.Printf("Failed to fetch list timeline: %v", err)
+	}
+
+	v.app.SetLoading(false)
+}
+
+func (v *HomeView) streamList(ctx context.Context, id mastodon.ID) {
+	events, err := v.app.client.StreamingList(ctx, id)
+	if err != nil {
+		log.Printf("Failed to start list streaming: %v", err)
+		return
+	}
+
+	for {
+		select {
+		case event := <-events:
+			if e, ok := event.(*mastodon.ErrorEvent); ok {
+				log.Printf("List streaming error %v\n", e.Error())
+				continue
+			}
+			v.app.RunOnMain(func() {
+				index := v.timeline.listIndex(id)
+				if index < 0 {
+					return
+				}
+				switch e := event.(type) {
+				case *mastodon.UpdateEvent:
+					v.timeline.PrependToTimeline(index, []TimelineItem{StatusItem{Status: e.Status}})
+				case *mastodon.UpdateEditEvent:
+					v.timeline.UpdateEdit(index, StatusItem{Status: e.Status})
+				case *mastodon.DeleteEvent:
+					v.timeline.DeleteFromTimeline(index, e.ID)
+				}
+			})
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Pushes the timeline of our server filters
+func (v *HomeView) openFilters() {
+	v.app.SetLoading(true)
+
+	filters, err := v.app.customClient.GetFilters(context.Background())
+	if err == nil {
+		items := make([]TimelineItem, len(filters))
+		for i, filter := range filters {
+			items[i] = FilterItem{Filter: filter}
 		}
+		v.app.RunOnMain(func() {
+			v.timeline.PushTimeline(Timeline{Kind: TimelineFilters, Items: items})
+		})
+	} else {
+		log.Printf("Failed to fetch filters: %v", err)
 	}
 
 	v.app.SetLoading(false)
 }
 
+// Returns the index of the open timeline of our filters, or -1 when it is
+// closed
+func (v *HomeView) filtersIndex() int {
+	for i := len(v.timeline.timelines) - 1; i >= 0; i-- {
+		if v.timeline.timelines[i].Kind == TimelineFilters {
+			return i
+		}
+	}
+	return -1
+}
+
+// Creates a filter that warns in every context, keywords are added to it
+// afterwards
+func (v *HomeView) createFilter(title string) {
+	filter, err := v.app.customClient.CreateFilter(context.Background(), &api.Filter{
+		Title:        title,
+		Context:      api.FilterContexts,
+		FilterAction: api.FilterActionWarn,
+	}, 0)
+	if err != nil {
+		log.Printf("Failed to create filter: %v", err)
+	}
+	v.app.RunOnMain(func() {
+		if err != nil {
+			v.app.footer.SetText("Failed to create filter")
+		} else if index := v.filtersIndex(); index >= 0 {
+			v.timeline.AppendToTimeline(index, []TimelineItem{FilterItem{Filter: filter}})
+		}
+	})
+}
+
+// Returns a page of our conversations. The pagination holds the max ID of
+// the next page, empty after the last one
+func (v *HomeView) fetchConversations(maxID mastodon.ID) ([]TimelineItem, *mastodon.Pagination, error) {
+	pg := &mastodon.Pagination{MaxID: maxID, Limit: 20}
+	conversations, err := v.app.client.GetConversations(context.Background(), pg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pg.MaxID == maxID {
+		// No Link header to the next page
+		pg.MaxID = ""
+	}
+	items := make([]TimelineItem, len(conversations))
+	for i, conversation := range conversations {
+		items[i] = ConversationItem{Conversation: conversation}
+	}
+	return items, pg, nil
+}
+
+// Pushes the timeline of our direct conversations and keeps it updated while
+// it is open
+func (v *HomeView) openConversations() {
+	v.app.SetLoading(true)
+
+	items, pg, err := v.fetchConversations("")
+	if err == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		v.app.RunOnMain(func() {
+			v.timeline.PushTimeline(Timeline{Kind: TimelineConversations, Items: items, NextMaxID: pg.MaxID, cancel: cancel})
+		})
+		go v.streamConversations(ctx)
+	} else {
+		log.Printf("Failed to fetch conversations: %v", err)
+	}
+
+	v.app.SetLoading(false)
+}
+
+func (v *HomeView) loadMoreConversations(index int) {
+	timeline := &v.timeline.timelines[index]
+	if timeline.NextMaxID == "" {
+		return
+	}
+
+	items, pg, err := v.fetchConversations(timeline.NextMaxID)
+	if err != nil {
+		log.Printf("Failed to fetch conversations: %v", err)
+		return
+	}
+	timeline.NextMaxID = pg.MaxID
+	v.timeline.AppendToTimeline(index, items)
+	v.app.vx.PostEvent(vaxis.Redraw{})
+}
+
+// Moves a conversation with a new status to the top of the timeline
+func (v *HomeView) updateConversation(conversation *mastodon.Conversation) {
+	index := v.timeline.conversationsIndex()
+	if index < 0 {
+		return
+	}
+	v.timeline.DeleteFromTimeline(index, conversation.ID)
+	v.timeline.PrependToTimeline(index, []TimelineItem{ConversationItem{Conversation: conversation}})
+}
+
+func (v *HomeView) streamConversations(ctx context.Context) {
+	events, err := v.app.client.StreamingDirect(ctx)
+	if err != nil {
+		log.Printf("Failed to start direct streaming: %v", err)
+		return
+	}
+
+	for {
+		select {
+		case event := <-events:
+			switch e := event.(type) {
+			case *mastodon.ConversationEvent:
+				v.app.RunOnMain(func() {
+					v.updateConversation(e.Conversation)
+				})
+			case *mastodon.ErrorEvent:
+				log.Printf("Direct streaming error %v\n", e.Error())
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Shows the thread of a conversation and marks it as read
+func (v *HomeView) openConversation(conversation *mastodon.Conversation) {
+	if conversation.Unread {
+		v.markConversationRead(conversation)
+	}
+	if conversation.LastStatus != nil {
+		v.openThread(conversation.LastStatus)
+	}
+}
+
+func (v *HomeView) markConversationRead(conversation *mastodon.Conversation) {
+	err := v.app.client.MarkConversationAsRead(context.Background(), conversation.ID)
+	if err != nil {
+		log.Printf("Failed to mark conversation as read: %v", err)
+	}
+	v.app.RunOnMain(func() {
+		if err != nil {
+			v.app.footer.SetText("Failed to mark as read")
+			return
+		}
+		read := *conversation
+		read.Unread = false
+		if index := v.timeline.conversationsIndex(); index >= 0 {
+			v.timeline.UpdateEdit(index, ConversationItem{Conversation: &read})
+		}
+	})
+}
+
+// Removes a conversation from our list. Its statuses are kept
+func (v *HomeView) removeConversation(conversation *mastodon.Conversation) {
+	err := v.app.client.DeleteConversation(context.Background(), conversation.ID)
+	if err != nil {
+		log.Printf("Failed to remove conversation: %v", err)
+	}
+	v.app.RunOnMain(func() {
+		if err != nil {
+			v.app.footer.SetText("Failed to remove conversation")
+		} else if index := v.timeline.conversationsIndex(); index >= 0 {
+			v.timeline.DeleteFromTimeline(index, conversation.ID)
+		}
+	})
+}
+
+// Returns a page of our bookmarks or favourites. They are paginated by the
+// time they were saved, so the pagination holds the max ID of the next page,
+// empty after the last one
+func (v *HomeView) fetchSaved(favourites bool, maxID mastodon.ID) ([]TimelineItem, *mastodon.Pagination, error) {
+	pg := &mastodon.Pagination{MaxID: maxID, Limit: 20}
+	var statuses []*mastodon.Status
+	var err error
+	if favourites {
+		statuses, err = v.app.client.GetFavourites(context.Background(), pg)
+	} else {
+		statuses, err = v.app.client.GetBookmarks(context.Background(), pg)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if pg.MaxID == maxID {
+		// No Link header to the next page
+		pg.MaxID = ""
+	}
+	items := make([]TimelineItem, len(statuses))
+	for i, s := range statuses {
+		items[i] = StatusItem{Status: s}
+	}
+	return items, pg, nil
+}
+
+// Pushes the timeline of our bookmarks or favourites
+func (v *HomeView) openSaved(favourites bool) {
+	v.app.SetLoading(true)
+
+	items, pg, err := v.fetchSaved(favourites, "")
+	if err == nil {
+		kind := TimelineBookmarks
+		if favourites {
+			kind = TimelineFavourites
+		}
+		v.timeline.PushTimeline(Timeline{Kind: kind, Items: items, NextMaxID: pg.MaxID})
+	} else {
+		log.Printf("Failed to fetch saved statuses: %v", err)
+	}
+
+	v.app.SetLoading(false)
+}
+
+func (v *HomeView) loadMoreSaved(index int) {
+	timeline := &v.timeline.timelines[index]
+	if timeline.NextMaxID == "" {
+		return
+	}
+
+	items, pg, err := v.fetchSaved(timeline.Kind == TimelineFavourites, timeline.NextMaxID)
+	if err != nil {
+		log.Printf("Failed to fetch saved statuses: %v", err)
+		return
+	}
+	timeline.NextMaxID = pg.MaxID
+	v.timeline.AppendToTimeline(index, items)
+	v.app.vx.PostEvent(vaxis.Redraw{})
+}
+
+// Favourites or bookmarks the selected status, or undoes it. A status undone
+// leaves the open timeline of our favourites or bookmarks
+func (v *HomeView) toggleSaved(favourite bool) {
+	item, ok := v.timeline.SelectedItem().(StatusItem)
+	if !ok || item.Status == nil {
+		return
+	}
+	status := item.Status
+	if status.Reblog != nil {
+		status = status.Reblog
+	}
+	go v.save(status, favourite)
+}
+
+// Sends a favourite or bookmark toggled by toggleSaved, then applies the
+// status the server returns on the main loop
+func (v *HomeView) save(status *mastodon.Status, favourite bool) {
+	ctx := context.Background()
+	client := v.app.client
+	var updated *mastodon.Status
+	var err error
+	var saved bool
+	if favourite {
+		saved, _ = status.Favourited.(bool)
+		if saved {
+			updated, err = client.Unfavourite(ctx, status.ID)
+		} else {
+			updated, err = client.Favourite(ctx, status.ID)
+		}
+	} else {
+		saved, _ = status.Bookmarked.(bool)
+		if saved {
+			updated, err = client.Unbookmark(ctx, status.ID)
+		} else {
+			updated, err = client.Bookmark(ctx, status.ID)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to update status: %v", err)
+		v.app.RunOnMain(func() {
+			v.app.footer.SetText("Failed to update status")
+		})
+		return
+	}
+
+	// Undoing is done in the background, so the answer may not show it yet
+	if favourite {
+		updated.Favourited = !saved
+	} else {
+		updated.Bookmarked = !saved
+	}
+	v.app.RunOnMain(func() {
+		v.timeline.UpdateStatus(updated)
+		if !saved {
+			return
+		}
+		for i := range v.timeline.timelines {
+			timeline := &v.timeline.timelines[i]
+			if (favourite && timeline.Kind == TimelineFavourites) || (!favourite && timeline.Kind == TimelineBookmarks) {
+				v.timeline.DeleteFromTimeline(i, updated.ID)
+			}
+		}
+	})
+}
+
+// Opens the composer for a new status, or for a reply to the selected status
+// or conversation. Replies are no more public than what they answer, and
+// those in a conversation stay direct
+func (v *HomeView) compose(reply bool) {
+	if !reply {
+		v.composeView.Open(nil, v.app.DefaultVisibility(), nil)
+		v.showingCompose = true
+		return
+	}
+
+	switch item := v.timeline.SelectedItem().(type) {
+	case StatusItem:
+		status := item.Status
+		if status.Reblog != nil {
+			status = status.Reblog
+		}
+		v.composeView.Open(status, narrowestVisibility(v.app.DefaultVisibility(), status.Visibility), nil)
+	case ConversationItem:
+		if item.LastStatus == nil {
+			return
+		}
+		v.composeView.Open(item.LastStatus, "direct", item.Accounts)
+	default:
+		return
+	}
+	v.showingCompose = true
+}
+
+// Returns the selected status, or the status it boosts
+func (v *HomeView) selectedStatus() *mastodon.Status {
+	item, ok := v.timeline.SelectedItem().(StatusItem)
+	if !ok || item.Status == nil {
+		return nil
+	}
+	if item.Reblog != nil {
+		return item.Reblog
+	}
+	return item.Status
+}
+
+// Opens the selected status in the composer when it is one of ours
+func (v *HomeView) editStatus() {
+	status := v.selectedStatus()
+	if status == nil {
+		return
+	}
+	if status.Account.ID != v.app.accountID {
+		v.app.footer.SetText("Only your own posts can be edited")
+		return
+	}
+	go v.loadEdit(status)
+}
+
+// Loads the source of a status to edit, then opens it on the main loop
+func (v *HomeView) loadEdit(status *mastodon.Status) {
+	source, err := v.app.client.GetStatusSource(context.Background(), status.ID)
+	if err != nil {
+		log.Printf("Failed to fetch status source: %v", err)
+	}
+	v.app.RunOnMain(func() {
+		if err != nil {
+			v.app.footer.SetText("Failed to load the post")
+			return
+		}
+		v.composeView.OpenEdit(status, source)
+		v.showingCompose = true
+	})
+}
+
+// Asks to delete the selected status when it is one of ours. A redraft opens
+// its text, attachments and poll in the composer once it is deleted
+func (v *HomeView) confirmDelete(redraft bool) {
+	status := v.selectedStatus()
+	if status == nil {
+		return
+	}
+	if status.Account.ID != v.app.accountID {
+		v.app.footer.SetText("Only your own posts can be deleted")
+		return
+	}
+	message := "Delete this post?"
+	if redraft {
+		message = "Delete and redraft? Boosts and favorites are lost"
+	}
+	v.app.ShowConfirm(message, func() {
+		go v.deleteStatus(status, redraft)
+	})
+}
+
+func (v *HomeView) deleteStatus(status *mastodon.Status, redraft bool) {
+	ctx := context.Background()
+	client := v.app.client
+	fail := func(text string, err error) {
+		log.Printf("%s: %v", text, err)
+		v.app.RunOnMain(func() {
+			v.app.footer.SetText(text)
+		})
+	}
+
+	// The source is only kept while the status exists
+	var source *mastodon.Source
+	var inReplyTo *mastodon.Status
+	if redraft {
+		var err error
+		source, err = client.GetStatusSource(ctx, status.ID)
+		if err != nil {
+			fail("Failed to load the post", err)
+			return
+		}
+		if id, ok := status.InReplyToID.(string); ok && id != "" {
+			inReplyTo, err = client.GetStatus(ctx, mastodon.ID(id))
+			if err != nil {
+				// The redraft is still posted, though no longer as a reply
+				log.Printf("Failed to fetch replied status: %v", err)
+			}
+		}
+	}
+
+	if err := client.DeleteStatus(ctx, status.ID); err != nil {
+		fail("Failed to delete the post", err)
+		return
+	}
+	v.app.RunOnMain(func() {
+		v.timeline.DeleteStatus(status.ID)
+		v.app.footer.SetText("Deleted")
+		if redraft {
+			v.composeView.OpenRedraft(status, source, inReplyTo)
+			v.showingCompose = true
+		}
+	})
+}
+
+// Pins the selected status to our profile, or unpins it
+func (v *HomeView) togglePin() {
+	status := v.selectedStatus()
+	if status == nil {
+		return
+	}
+	if status.Account.ID != v.app.accountID {
+		v.app.footer.SetText("Only your own posts can be pinned")
+		return
+	}
+	pinned, _ := status.Pinned.(bool)
+	go v.pin(status, pinned)
+}
+
+// Sends a pin toggled by togglePin, then applies the status the server
+// returns on the main loop
+func (v *HomeView) pin(status *mastodon.Status, pinned bool) {
+	client := v.app.customClient
+	var updated *mastodon.Status
+	var err error
+	if pinned {
+		updated, err = client.UnpinStatus(context.Background(), status.ID)
+	} else {
+		updated, err = client.PinStatus(context.Background(), status.ID)
+	}
+	if err != nil {
+		log.Printf("Failed to pin status: %v", err)
+	}
+	v.app.RunOnMain(func() {
+		if err != nil {
+			v.app.footer.SetText("Failed to update the pin")
+			return
+		}
+		updated.Pinned = !pinned
+		if pinned {
+			v.app.footer.SetText("Unpinned")
+		} else {
+			v.app.footer.SetText("Pinned")
+		}
+		v.timeline.UpdateStatus(updated)
+	})
+}
+
+// Mutes notifications from the conversation of the selected status, or
+// unmutes them
+func (v *HomeView) toggleConversationMute() {
+	status := v.selectedStatus()
+	if status == nil {
+		return
+	}
+
+	client := v.app.customClient
+	muted, _ := status.Muted.(bool)
+	var updated *mastodon.Status
+	var err error
+	if muted {
+		updated, err = client.UnmuteConversation(context.Background(), status.ID)
+	} else {
+		updated, err = client.MuteConversation(context.Background(), status.ID)
+	}
+	if err != nil {
+		log.Printf("Failed to mute conversation: %v", err)
+		v.app.footer.SetText("Failed to update the conversation mute")
+		v.app.vx.PostEvent(vaxis.Redraw{})
+		return
+	}
+	updated.Muted = !muted
+	if muted {
+		v.app.footer.SetText("Conversation unmuted")
+	} else {
+		v.app.footer.SetText("Conversation muted")
+	}
+	v.timeline.UpdateStatus(updated)
+	v.app.vx.PostEvent(vaxis.Redraw{})
+}
+
+// Shows how the selected status changed over its edits
+func (v *HomeView) showHistory() {
+	status := v.selectedStatus()
+	if status == nil {
+		return
+	}
+	if status.EditedAt.IsZero() {
+		v.app.footer.SetText("This post has not been edited")
+		return
+	}
+	v.historyView.Open(status)
+	v.showingHistory = true
+}
+
+// Adds a reply to the open threads of the status it answers. New statuses
+// reach the home timeline through the stream
+func (v *HomeView) addReply(status, inReplyTo *mastodon.Status) {
+	if inReplyTo == nil {
+		return
+	}
+	for i := range v.timeline.timelines {
+		timeline := &v.timeline.timelines[i]
+		if timeline.Kind != TimelineThread {
+			continue
+		}
+		for _, item := range timeline.Items {
+			if item.ID() == inReplyTo.ID {
+				v.timeline.AppendToTimeline(i, []TimelineItem{StatusItem{Status: status}})
+				break
+			}
+		}
+	}
+}
+
 func (v *HomeView) startStreaming() {
 	ctx := context.Background()
 
@@ -273,7 +1131,8 @@ func (v *HomeView) handleStreamingEvent(event mastodon.Event) {
 		v.app.vx.PostEvent(vaxis.Redraw{})
 
 	case *mastodon.UpdateEditEvent:
-		v.timeline.UpdateEdit(0, StatusItem{Status: e.Status})
+		// The edited status may be open in any timeline, boosted or not
+		v.timeline.UpdateStatus(e.Status)
 		v.app.vx.PostEvent(vaxis.Redraw{})
 
 	case *mastodon.NotificationEvent:
@@ -282,7 +1141,7 @@ func (v *HomeView) handleStreamingEvent(event mastodon.Event) {
 		v.app.vx.PostEvent(vaxis.Redraw{})
 
 	case *mastodon.DeleteEvent:
-		v.timeline.DeleteFromTimeline(0, e.ID)
+		v.timeline.DeleteStatus(e.ID)
 		v.app.vx.PostEvent(vaxis.Redraw{})
 
 	case *mastodon.ErrorEvent:
@@ -318,19 +1177,22 @@ func (v *HomeView) selectedStatusLinks() []LinkItem {
 		if att.URL == "" || seen[att.URL] {
 			continue
 		}
-		if att.Type != "image" && att.Type != "video" && att.Type != "gifv" {
+		if att.Type != "image" && att.Type != "video" && att.Type != "gifv" && att.Type != "audio" {
 			continue
 		}
 		seen[att.URL] = true
 		label := att.Description
 		if label == "" {
-			if att.Type == "image" {
+			switch att.Type {
+			case "image":
 				label = "[image]"
-			} else {
+			case "audio":
+				label = "[audio]"
+			default:
 				label = "[video]"
 			}
 		}
-		items = append(items, LinkItem{Label: label, URL: att.URL})
+		items = append(items, LinkItem{Label: label, URL: att.URL, Type: att.Type})
 	}
 	sort.SliceStable(items, func(i, j int) bool {
 		return utils.IsTagLink(items[j].URL) && !utils.IsTagLink(items[i].URL)
@@ -338,6 +1200,40 @@ func (v *HomeView) selectedStatusLinks() []LinkItem {
 	return items
 }
 
+func (v *HomeView) selectedStatusMedia() []mastodon.Attachment {
+	item, ok := v.timeline.SelectedItem().(StatusItem)
+	if !ok || item.Status == nil {
+		return nil
+	}
+	status := item.Status
+	if status.Reblog != nil {
+		status = status.Reblog
+	}
+	return status.MediaAttachments
+}
+
+func (v *HomeView) saveMedia(url string) {
+	v.app.footer.SetText("Saving...")
+	v.app.vx.PostEvent(vaxis.Redraw{})
+
+	path, err := utils.SaveFile(context.Background(), url, v.app.config.Media.DownloadDir)
+	if err != nil {
+		log.Printf("Failed to save media %s: %v", url, err)
+		v.app.footer.SetText("Failed to save media")
+	} else {
+		v.app.footer.SetText("Saved to " + path)
+	}
+	v.app.vx.PostEvent(vaxis.Redraw{})
+}
+
+// Resets the detail views for a newly selected item, and starts loading what
+// an account shows the first time it is selected
+func (v *HomeView) selectionChanged(item TimelineItem) {
+	v.statusView.ResetScroll()
+	if item, ok := item.(AccountItem); ok {
+		v.accountView.Select(item.Account)
+	}
+}
 
 func (v *HomeView) Draw(win vaxis.Window) {
 	var (
@@ -356,6 +1252,11 @@ func (v *HomeView) Draw(win vaxis.Window) {
 		Foreground: vaxis.IndexColor(0),
 	}
 
+	if v.showingMedia {
+		v.mediaView.Draw(win.New(0, 1, width, height-3))
+		return
+	}
+
 	total := leftRatio + rightRatio
 	split := width * leftRatio / total
 
@@ -368,24 +1269,34 @@ func (v *HomeView) Draw(win vaxis.Window) {
 	selectedItem := v.timeline.SelectedItem()
 	isDetailFocused := v.focusedView == 1
 
-	if v.showingLinks {
+	if v.showingCompose {
+		v.composeView.Draw(detailWin)
+	} else if v.showingHistory {
+		v.historyView.Draw(detailWin)
+	} else if v.showingLinks {
 		v.linksView.Draw(detailWin, v.focusedView == 1)
 	} else if selectedItem != nil {
 		currentID := selectedItem.ID()
 		if currentID != v.lastSelectedID {
-			v.statusView.ResetScroll()
+			v.selectionChanged(selectedItem)
 			v.lastSelectedID = currentID
 		}
 		switch item := selectedItem.(type) {
 		case StatusItem:
-			v.statusView.Draw(detailWin, isDetailFocused, item.Status)
+			v.statusView.Draw(detailWin, isDetailFocused, item.Status, v.timeline.filterWarning(item.Status))
 		case AccountItem:
-			v.accountView.Draw(detailWin, isDetailFocused, item.Account)
+			v.accountView.Draw(detailWin, isDetailFocused, item.Account, v.timeline.timelines[v.timeline.index].AccountTab)
+		case ListItem:
+			v.listView.Draw(detailWin, isDetailFocused, item.List)
+		case FilterItem:
+			v.filterView.Draw(detailWin, isDetailFocused, item.Filter)
+		case ConversationItem:
+			v.statusView.Draw(detailWin, isDetailFocused, item.LastStatus, "")
 		default:
-			v.statusView.Draw(detailWin, isDetailFocused, nil)
+			v.statusView.Draw(detailWin, isDetailFocused, nil, "")
 		}
 	} else {
-		v.statusView.Draw(detailWin, isDetailFocused, nil)
+		v.statusView.Draw(detailWin, isDetailFocused, nil, "")
 	}
 
 	for row := 0; row < height-2; row++ {
@@ -398,7 +1309,64 @@ func (v *HomeView) Draw(win vaxis.Window) {
 	}
 }
 
+// Reports whether the account view has the focus, so keys go to it
+func (v *HomeView) accountFocused() bool {
+	_, ok := v.timeline.SelectedItem().(AccountItem)
+	return ok && v.focusedView == 1
+}
+
+// Reports whether the list or filter editor has the focus, so its keys are
+// not taken as global ones
+func (v *HomeView) editorFocused() bool {
+	switch v.timeline.SelectedItem().(type) {
+	case ListItem, FilterItem:
+		return v.focusedView == 1
+	}
+	return false
+}
+
 func (v *HomeView) HandleKey(key vaxis.Key) {
+	if v.showingMedia {
+		switch v.mediaView.HandleKey(key) {
+		case "save":
+			if media := v.mediaView.Current(); media != nil && media.URL != "" {
+				go v.saveMedia(media.URL)
+			}
+		case "open":
+			if media := v.mediaView.Current(); media != nil && media.URL != "" {
+				v.app.OpenURL(media.URL, media.Type)
+			}
+		case "close":
+			v.showingMedia = false
+		}
+		return
+	}
+	if v.showingCompose {
+		v.composeView.HandleKey(key)
+		return
+	}
+	if v.showingHistory {
+		if v.historyView.HandleKey(key) == "close" {
+			v.showingHistory = false
+		}
+		return
+	}
+	if _, ok := v.timeline.SelectedItem().(StatusItem); ok && v.focusedView == 1 && v.statusView.capturesKeys() {
+		v.statusView.HandleKey(key)
+		return
+	}
+	if _, ok := v.timeline.SelectedItem().(AccountItem); ok && v.focusedView == 1 && v.accountView.capturesKeys() {
+		v.accountView.HandleKey(key)
+		return
+	}
+	if _, ok := v.timeline.SelectedItem().(ListItem); ok && v.focusedView == 1 && v.listView.showingMenu {
+		v.listView.HandleKey(key)
+		return
+	}
+	if _, ok := v.timeline.SelectedItem().(FilterItem); ok && v.focusedView == 1 && v.filterView.showingMenu {
+		v.filterView.HandleKey(key)
+		return
+	}
 	if v.showingLinks {
 		if key.Matches('h') {
 			v.focusedView = 0
@@ -419,9 +1387,8 @@ func (v *HomeView) HandleKey(key vaxis.Key) {
 		result := v.linksView.HandleKey(key)
 		switch result {
 		case "open":
-			if err := utils.OpenBrowser(v.linksView.links[v.linksView.selected].URL); err != nil {
-				log.Printf("Failed to open URL: %v", err)
-			}
+			link := v.linksView.links[v.linksView.selected]
+			v.app.OpenURL(link.URL, link.Type)
 		case "close":
 			v.showingLinks = false
 		}
@@ -433,7 +1400,7 @@ func (v *HomeView) HandleKey(key vaxis.Key) {
 		v.focusedView = 0
 	} else if key.Matches('l') {
 		v.focusedView = 1
-	} else if key.Matches('r') && !v.app.loading && !v.isStreaming {
+	} else if key.Matches('r') && !v.app.loading && !v.isStreaming && !v.editorFocused() {
 		go v.reloadHomeTimeline()
 	} else if key.Matches('t') && !v.app.loading {
 		go v.getStatusContext()
@@ -441,6 +1408,81 @@ func (v *HomeView) HandleKey(key vaxis.Key) {
 		go v.goToAccountTimeline(false)
 	} else if key.Matches('U') && !v.app.loading {
 		go v.goToAccountTimeline(true)
+	} else if key.Matches('L') && !v.accountFocused() && !v.app.loading {
+		go v.openLists()
+	} else if key.Matches('F') && !v.accountFocused() && !v.app.loading {
+		go v.openFilters()
+	} else if key.Matches('D') && !v.accountFocused() && !v.editorFocused() && !v.app.loading {
+		go v.openConversations()
+	} else if key.Matches('B') && !v.accountFocused() && !v.editorFocused() && !v.app.loading {
+		go v.openSaved(false)
+	} else if key.Matches('*') && !v.accountFocused() && !v.editorFocused() && !v.app.loading {
+		go v.openSaved(true)
+	} else if key.Matches('f') && !v.accountFocused() && !v.editorFocused() {
+		v.toggleSaved(true)
+	} else if key.Matches('b') && !v.accountFocused() && !v.editorFocused() {
+		v.toggleSaved(false)
+	} else if key.Matches('c') && !v.accountFocused() && !v.editorFocused() {
+		v.compose(false)
+	} else if key.Matches('R') && !v.accountFocused() && !v.editorFocused() {
+		v.compose(true)
+	} else if key.Matches('E') && !v.accountFocused() && !v.editorFocused() {
+		v.editStatus()
+	} else if key.Matches('e') && !v.accountFocused() && !v.editorFocused() {
+		v.showHistory()
+	} else if _, ok := v.timeline.SelectedItem().(StatusItem); ok && key.Matches('d') && !v.accountFocused() && !v.editorFocused() {
+		v.confirmDelete(false)
+	} else if key.Matches('w') && !v.accountFocused() && !v.editorFocused() {
+		v.confirmDelete(true)
+	} else if key.Matches('P') && !v.accountFocused() && !v.editorFocused() {
+		v.togglePin()
+	} else if key.Matches('M') && !v.accountFocused() && !v.editorFocused() {
+		go v.toggleConversationMute()
+	} else if current := v.timeline.Current(); key.Matches('n') && current != nil && current.Kind == TimelineLists {
+		v.app.ShowPrompt("New list", "", func(title string) {
+			if title != "" {
+				go v.createList(title)
+			}
+		})
+	} else if key.Matches('n') && current != nil && current.Kind == TimelineFilters {
+		v.app.ShowPrompt("New filter", "", func(title string) {
+			if title != "" {
+				go v.createFilter(title)
+			}
+		})
+	} else if item, ok := v.timeline.SelectedItem().(ListItem); ok && key.Matches(vaxis.KeyEnter) && v.focusedView == 0 && !v.app.loading {
+		go v.openList(item.List)
+	} else if item, ok := v.timeline.SelectedItem().(ConversationItem); ok && key.Matches(vaxis.KeyEnter) && !v.app.loading {
+		go v.openConversation(item.Conversation)
+	} else if item, ok := v.timeline.SelectedItem().(ConversationItem); ok && key.Matches('x') && item.Unread {
+		go v.markConversationRead(item.Conversation)
+	} else if item, ok := v.timeline.SelectedItem().(ConversationItem); ok && key.Matches('d') {
+		v.app.ShowConfirm("Remove this conversation? Its posts are kept", func() {
+			go v.removeConversation(item.Conversation)
+		})
+	} else if key.Matches('z') {
+		if item, ok := v.timeline.SelectedItem().(StatusItem); ok {
+			if v.timeline.filterWarning(item.Status) != "" {
+				v.timeline.RevealFiltered(item.Status)
+			} else {
+				v.statusView.ToggleReveal(item.Status)
+			}
+		}
+	} else if key.Matches('H') {
+		v.timeline.ToggleHidden()
+	} else if key.Matches('S') {
+		v.app.footer.SetText(utils.ImageCache.Stats().String())
+	} else if key.Matches('I') {
+		if utils.ImageCache.ToggleLoading() {
+			v.app.footer.SetText("Images on")
+		} else {
+			v.app.footer.SetText("Images off")
+		}
+	} else if key.Matches('m') {
+		if media := v.selectedStatusMedia(); len(media) > 0 {
+			v.mediaView.SetMedia(media, 0)
+			v.showingMedia = true
+		}
 	} else if key.Matches('i') {
 		links := v.selectedStatusLinks()
 		if len(links) > 0 {
@@ -466,6 +1508,10 @@ func (v *HomeView) HandleKey(key vaxis.Key) {
 					v.statusView.HandleKey(key)
 				case AccountItem:
 					v.accountView.HandleKey(key)
+				case ListItem:
+					v.listView.HandleKey(key)
+				case FilterItem:
+					v.filterView.HandleKey(key)
 				}
 			}
 		}
@@ -9,6 +9,8 @@ import (
 type LinkItem struct {
 	Label string
 	URL   string
+	// Type is the attachment type for media links, empty otherwise
+	Type string
 }
 
 type LinksView struct {
@@ -0,0 +1,311 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"git.sr.ht/~rockorager/vaxis"
+	"github.com/AbeEstrada/tuit/api"
+	"github.com/mattn/go-mastodon"
+)
+
+type repliesPolicy struct {
+	label  string
+	policy string
+}
+
+var repliesPolicies = []repliesPolicy{
+	{"Any followed user", api.RepliesPolicyFollowed},
+	{"Members of the list", api.RepliesPolicyList},
+	{"No one", api.RepliesPolicyNone},
+}
+
+func repliesPolicyLabel(policy string) string {
+	for _, p := range repliesPolicies {
+		if p.policy == policy {
+			return p.label
+		}
+	}
+	return repliesPolicies[0].label
+}
+
+// ListView shows the settings and members of a list and edits them
+type ListView struct {
+	app          *App
+	mu           sync.Mutex
+	members      map[mastodon.ID][]*mastodon.Account
+	list         *api.List
+	selected     int
+	scrollOffset int
+	menu         *MenuView
+	showingMenu  bool
+	onMenuSelect func(index int)
+	onOpen       func(list *api.List)
+	onUpdate     func(list *api.List)
+	onDelete     func(id mastodon.ID)
+}
+
+func CreateListView() *ListView {
+	return &ListView{
+		members: make(map[mastodon.ID][]*mastodon.Account),
+		menu:    CreateMenuView(),
+	}
+}
+
+func (v *ListView) SetApp(app *App) {
+	v.app = app
+}
+
+// Returns the members of the list, or nil while they load
+func (v *ListView) listMembers(id mastodon.ID) []*mastodon.Account {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.members[id]
+}
+
+func (v *ListView) setMembers(id mastodon.ID, members []*mastodon.Account) {
+	v.mu.Lock()
+	v.members[id] = members
+	v.mu.Unlock()
+}
+
+func (v *ListView) fetchMembers(id mastodon.ID) {
+	members, err := v.app.customClient.GetListMembers(context.Background(), id)
+	if err != nil {
+		log.Printf("Failed to fetch list members: %v", err)
+		return
+	}
+	if members == nil {
+		members = []*mastodon.Account{}
+	}
+	v.setMembers(id, members)
+	v.app.vx.PostEvent(vaxis.Redraw{})
+}
+
+// Saves the list with the changes made by fn
+func (v *ListView) update(list *api.List, fn func(list *api.List)) {
+	changed := *list
+	fn(&changed)
+	updated, err := v.app.customClient.UpdateList(context.Background(), &changed)
+	if err != nil {
+		log.Printf("Failed to update list: %v", err)
+		v.app.footer.SetText("Failed to update list")
+	} else if v.onUpdate != nil {
+		v.onUpdate(updated)
+	}
+	v.app.vx.PostEvent(vaxis.Redraw{})
+}
+
+func (v *ListView) delete(list *api.List) {
+	if err := v.app.customClient.DeleteList(context.Background(), list.ID); err != nil {
+		log.Printf("Failed to delete list: %v", err)
+		v.app.footer.SetText("Failed to delete list")
+	} else {
+		v.app.footer.SetText("Deleted " + list.Title)
+		if v.onDelete != nil {
+			v.onDelete(list.ID)
+		}
+	}
+	v.app.vx.PostEvent(vaxis.Redraw{})
+}
+
+// Searches the accounts we follow and offers the results to add to the list
+func (v *ListView) searchMembers(list *api.List, query string) {
+	accounts, err := v.app.customClient.SearchAccounts(context.Background(), query, true, 20)
+	if err != nil {
+		log.Printf("Failed to search accounts: %v", err)
+		v.app.footer.SetText("Failed to search accounts")
+		v.app.vx.PostEvent(vaxis.Redraw{})
+		return
+	}
+	if len(accounts) == 0 {
+		v.app.footer.SetText("No followed accounts match " + query)
+		v.app.vx.PostEvent(vaxis.Redraw{})
+		return
+	}
+
+	items := make([]MenuItem, len(accounts))
+	for i, account := range accounts {
+		items[i] = MenuItem{Label: accountLabel(account)}
+	}
+	v.openMenu("Add to "+list.Title, items, func(index int) {
+		go v.addMember(list, accounts[index])
+	})
+	v.app.vx.PostEvent(vaxis.Redraw{})
+}
+
+func (v *ListView) addMember(list *api.List, account *mastodon.Account) {
+	if err := v.app.customClient.AddListMembers(context.Background(), list.ID, account.ID); err != nil {
+		log.Printf("Failed to add to list: %v", err)
+		v.app.footer.SetText("Failed to add @" + account.Acct)
+		v.app.vx.PostEvent(vaxis.Redraw{})
+		return
+	}
+	v.app.footer.SetText("Added @" + account.Acct + " to " + list.Title)
+	v.fetchMembers(list.ID)
+}
+
+func (v *ListView) removeMember(list *api.List, account *mastodon.Account) {
+	if err := v.app.customClient.RemoveListMembers(context.Background(), list.ID, account.ID); err != nil {
+		log.Printf("Failed to remove from list: %v", err)
+		v.app.footer.SetText("Failed to remove @" + account.Acct)
+		v.app.vx.PostEvent(vaxis.Redraw{})
+		return
+	}
+	v.app.footer.SetText("Removed @" + account.Acct + " from " + list.Title)
+	v.fetchMembers(list.ID)
+}
+
+func accountLabel(account *mastodon.Account) string {
+	if account.DisplayName == "" {
+		return "@" + account.Acct
+	}
+	return account.DisplayName + " @" + account.Acct
+}
+
+func (v *ListView) Draw(win vaxis.Window, focused bool, list *api.List) {
+	if list == nil {
+		win.Println(0, vaxis.Segment{Text: ""})
+		return
+	}
+
+	if v.list == nil || v.list.ID != list.ID {
+		// Refreshed every time another list is shown
+		go v.fetchMembers(list.ID)
+		v.showingMenu = false
+		v.selected = 0
+		v.scrollOffset = 0
+	}
+	v.list = list
+
+	if v.showingMenu {
+		v.menu.Draw(win, focused)
+		return
+	}
+
+	width, height := win.Size()
+	headingStyle := vaxis.Style{Attribute: vaxis.AttrBold}
+	dimStyle := vaxis.Style{Attribute: vaxis.AttrDim}
+
+	win.New(0, 0, width, 1).PrintTruncate(0, vaxis.Segment{Text: list.Title, Style: headingStyle})
+	win.New(0, 2, width, 1).PrintTruncate(0, vaxis.Segment{Text: "Replies shown to: " + repliesPolicyLabel(list.RepliesPolicy)})
+	exclusive := "Members also appear in Home"
+	if list.Exclusive {
+		exclusive = "Exclusive, members are hidden from Home"
+	}
+	win.New(0, 3, width, 1).PrintTruncate(0, vaxis.Segment{Text: exclusive})
+
+	members := v.listMembers(list.ID)
+	if members == nil {
+		win.Println(5, vaxis.Segment{Text: "Loading members...", Style: dimStyle})
+	} else {
+		win.Println(5, vaxis.Segment{Text: fmt.Sprintf("Members (%d)", len(members)), Style: headingStyle})
+		v.selected = min(v.selected, max(len(members)-1, 0))
+
+		rows := height - 9
+		if v.selected < v.scrollOffset {
+			v.scrollOffset = v.selected
+		} else if rows > 0 && v.selected >= v.scrollOffset+rows {
+			v.scrollOffset = v.selected - rows + 1
+		}
+		for i := v.scrollOffset; i < len(members) && i-v.scrollOffset < rows; i++ {
+			var attr vaxis.AttributeMask
+			if i == v.selected && focused {
+				attr = vaxis.AttrReverse
+			}
+			win.New(0, 6+i-v.scrollOffset, width, 1).PrintTruncate(0, vaxis.Segment{
+				Text:  " " + accountLabel(members[i]),
+				Style: vaxis.Style{Attribute: attr},
+			})
+		}
+	}
+
+	if focused {
+		win.New(0, height-2, width, 1).PrintTruncate(0, vaxis.Segment{
+			Text:  "Enter open · r rename · p replies · x exclusive · a add · d remove · D delete list",
+			Style: dimStyle,
+		})
+	}
+}
+
+func (v *ListView) openMenu(title string, items []MenuItem, onSelect func(index int)) {
+	v.menu.SetItems(title, items, false)
+	v.onMenuSelect = onSelect
+	v.showingMenu = true
+}
+
+func (v *ListView) HandleKey(key vaxis.Key) {
+	if v.showingMenu {
+		switch v.menu.HandleKey(key) {
+		case "select":
+			v.showingMenu = false
+			v.onMenuSelect(v.menu.Selected())
+		case "close":
+			v.showingMenu = false
+		}
+		return
+	}
+
+	list := v.list
+	if list == nil {
+		return
+	}
+	members := v.listMembers(list.ID)
+
+	switch {
+	case key.Matches('j'), key.Matches(vaxis.KeyDown):
+		if v.selected < len(members)-1 {
+			v.selected++
+		}
+	case key.Matches('k'), key.Matches(vaxis.KeyUp):
+		if v.selected > 0 {
+			v.selected--
+		}
+	case key.Matches(vaxis.KeyEnter):
+		if v.onOpen != nil {
+			v.onOpen(list)
+		}
+	case key.Matches('r'):
+		v.app.ShowPrompt("Rename list", list.Title, func(title string) {
+			if title == "" || title == list.Title {
+				return
+			}
+			go v.update(list, func(list *api.List) {
+				list.Title = title
+			})
+		})
+	case key.Matches('p'):
+		items := make([]MenuItem, len(repliesPolicies))
+		for i, p := range repliesPolicies {
+			items[i] = MenuItem{Label: p.label}
+		}
+		v.openMenu("Show replies to", items, func(index int) {
+			go v.update(list, func(list *api.List) {
+				list.RepliesPolicy = repliesPolicies[index].policy
+			})
+		})
+	case key.Matches('x'):
+		go v.update(list, func(list *api.List) {
+			list.Exclusive = !list.Exclusive
+		})
+	case key.Matches('a'):
+		v.app.ShowPrompt("Add account", "", func(query string) {
+			if query != "" {
+				go v.searchMembers(list, query)
+			}
+		})
+	case key.Matches('d'):
+		if v.selected < len(members) {
+			go v.removeMember(list, members[v.selected])
+		}
+	case key.Matches('D'):
+		items := []MenuItem{{Label: "Delete"}, {Label: "Cancel"}}
+		v.openMenu("Delete "+list.Title+"?", items, func(index int) {
+			if index == 0 {
+				go v.delete(list)
+			}
+		})
+	}
+}
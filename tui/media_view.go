@@ -0,0 +1,167 @@
+package tui
+
+import (
+	"fmt"
+
+	"git.sr.ht/~rockorager/vaxis"
+	"github.com/AbeEstrada/tuit/utils"
+	"github.com/mattn/go-mastodon"
+)
+
+const maxCaptionRows = 4
+
+// MediaView shows the media attachments of a status one at a time, using the
+// whole screen
+type MediaView struct {
+	media []mastodon.Attachment
+	index int
+	fill  bool
+}
+
+func CreateMediaView() *MediaView {
+	return &MediaView{}
+}
+
+func (v *MediaView) SetMedia(media []mastodon.Attachment, index int) {
+	v.media = media
+	v.index = min(max(index, 0), len(media)-1)
+}
+
+// Returns the attachment being shown
+func (v *MediaView) Current() *mastodon.Attachment {
+	if v.index < 0 || v.index >= len(v.media) {
+		return nil
+	}
+	return &v.media[v.index]
+}
+
+// Returns the URL of the full image to show for an attachment. Videos and
+// audio cannot be drawn, so their preview is used instead
+func fullImageURL(media *mastodon.Attachment) string {
+	if media.Type == "image" && media.URL != "" {
+		return media.URL
+	}
+	return media.PreviewURL
+}
+
+func (v *MediaView) Draw(win vaxis.Window) {
+	media := v.Current()
+	if media == nil {
+		return
+	}
+	width, height := win.Size()
+
+	zoom := "fit"
+	if v.fill {
+		zoom = "fill"
+	}
+	win.Println(0,
+		vaxis.Segment{
+			Text:  fmt.Sprintf("Media %d/%d", v.index+1, len(v.media)),
+			Style: vaxis.Style{Attribute: vaxis.AttrBold},
+		},
+		vaxis.Segment{Text: fmt.Sprintf(" · %s · %s", media.Type, zoom)},
+		vaxis.Segment{
+			Text:  "   h/l previous/next · f fit/fill · o open · s save · q close",
+			Style: vaxis.Style{Attribute: vaxis.AttrDim},
+		},
+	)
+
+	captionRows := 0
+	var caption vaxis.Segment
+	if media.Description != "" {
+		caption = vaxis.Segment{Text: media.Description}
+		_, captionRows = wrapText(win.New(0, -height*4, width, height*4), nil, caption)
+		captionRows = min(captionRows, maxCaptionRows)
+		wrapText(win.New(0, height-captionRows, width, captionRows), nil, caption)
+	}
+
+	imageTop := 2
+	imageWidth := width
+	imageHeight := height - imageTop - captionRows - 1
+	if imageWidth <= 0 || imageHeight <= 0 {
+		return
+	}
+
+	if !utils.ImageCache.Enabled() {
+		win.Println(imageTop, vaxis.Segment{
+			Text:  "Images are turned off, press o to open the file",
+			Style: vaxis.Style{Attribute: vaxis.AttrDim},
+		})
+		return
+	}
+
+	img, ok := v.image(media, imageWidth, imageHeight)
+	if !ok {
+		if media.BlurHash != "" {
+			placeholderWidth, placeholderHeight := v.placeholderSize(media, imageWidth, imageHeight)
+			x := (imageWidth - placeholderWidth) / 2
+			y := imageTop + (imageHeight-placeholderHeight)/2
+			if placeholder, ok := utils.ImageCache.GetBlurhash(media.BlurHash, placeholderWidth, placeholderHeight); ok {
+				placeholder.Draw(win.New(x, y, placeholderWidth, placeholderHeight))
+			}
+		}
+		return
+	}
+
+	cellWidth, cellHeight := img.CellSize()
+	x := (imageWidth - cellWidth) / 2
+	y := imageTop + (imageHeight-cellHeight)/2
+	img.Draw(win.New(x, y, cellWidth, cellHeight))
+}
+
+// Returns the full image scaled to the current zoom, falling back to the
+// preview while the full image loads
+func (v *MediaView) image(media *mastodon.Attachment, width, height int) (vaxis.Image, bool) {
+	get := utils.ImageCache.GetFitted
+	if v.fill {
+		get = utils.ImageCache.GetFilled
+	}
+
+	url := fullImageURL(media)
+	if url == "" {
+		return nil, false
+	}
+	if img, ok := get(url, width, height); ok {
+		return img, true
+	}
+	if media.PreviewURL != "" && media.PreviewURL != url {
+		return get(media.PreviewURL, width, height)
+	}
+	return nil, false
+}
+
+// Returns the size in cells of the placeholder for the attachment, keeping
+// the aspect ratio of the original when it is known
+func (v *MediaView) placeholderSize(media *mastodon.Attachment, width, height int) (int, int) {
+	meta := media.Meta.Original
+	if v.fill || meta.Width <= 0 || meta.Height <= 0 {
+		return width, height
+	}
+	// Cells are about twice as tall as they are wide
+	placeholderHeight := min(height, int(float64(width)*float64(meta.Height)/float64(meta.Width)*0.5))
+	placeholderWidth := min(width, int(float64(placeholderHeight)*float64(meta.Width)/float64(meta.Height)*2))
+	return max(placeholderWidth, 1), max(placeholderHeight, 1)
+}
+
+func (v *MediaView) HandleKey(key vaxis.Key) string {
+	switch {
+	case key.Matches('l'), key.Matches('j'), key.Matches(vaxis.KeyRight):
+		if v.index < len(v.media)-1 {
+			v.index++
+		}
+	case key.Matches('h'), key.Matches('k'), key.Matches(vaxis.KeyLeft):
+		if v.index > 0 {
+			v.index--
+		}
+	case key.Matches('f'):
+		v.fill = !v.fill
+	case key.Matches('s'):
+		return "save"
+	case key.Matches('o'), key.Matches(vaxis.KeyEnter):
+		return "open"
+	case key.Matches('q'), key.Matches(vaxis.KeyEsc):
+		return "close"
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package tui
+
+import (
+	"git.sr.ht/~rockorager/vaxis"
+)
+
+type MenuItem struct {
+	Label string
+	// Checked marks items that are currently on, for menus of toggles
+	Checked bool
+}
+
+// MenuView is a list of choices drawn in place of a pane, for actions that
+// need a pick from the user
+type MenuView struct {
+	title    string
+	items    []MenuItem
+	selected int
+	toggles  bool
+}
+
+func CreateMenuView() *MenuView {
+	return &MenuView{}
+}
+
+// Replaces the menu contents. Menus of toggles show a check box on every item
+// and stay open when one is selected
+func (v *MenuView) SetItems(title string, items []MenuItem, toggles bool) {
+	v.title = title
+	v.items = items
+	v.selected = 0
+	v.toggles = toggles
+}
+
+// Checks or unchecks an item of a menu of toggles
+func (v *MenuView) SetChecked(index int, checked bool) {
+	if index >= 0 && index < len(v.items) {
+		v.items[index].Checked = checked
+	}
+}
+
+func (v *MenuView) Selected() int {
+	return v.selected
+}
+
+func (v *MenuView) Draw(win vaxis.Window, focused bool) {
+	_, height := win.Size()
+
+	win.Println(0, vaxis.Segment{
+		Text:  v.title,
+		Style: vaxis.Style{Attribute: vaxis.AttrBold},
+	})
+
+	for i, item := range v.items {
+		y := i + 2
+		if y >= height-1 {
+			break
+		}
+
+		label := " " + item.Label
+		if v.toggles {
+			box := "☐"
+			if item.Checked {
+				box = "☑"
+			}
+			label = " " + box + label
+		}
+
+		var attr vaxis.AttributeMask
+		if i == v.selected && focused {
+			attr = vaxis.AttrReverse
+		}
+		win.New(0, y, -1, 1).PrintTruncate(0, vaxis.Segment{
+			Text:  label,
+			Style: vaxis.Style{Attribute: attr},
+		})
+	}
+}
+
+// Returns "select" when an item is chosen and "close" when the menu is dismissed
+func (v *MenuView) HandleKey(key vaxis.Key) string {
+	switch {
+	case key.Matches('j'), key.Matches(vaxis.KeyDown):
+		if v.selected < len(v.items)-1 {
+			v.selected++
+		}
+	case key.Matches('k'), key.Matches(vaxis.KeyUp):
+		if v.selected > 0 {
+			v.selected--
+		}
+	case key.Matches(vaxis.KeyEnter), key.Matches(vaxis.KeySpace):
+		if len(v.items) > 0 {
+			return "select"
+		}
+	case key.Matches('q'), key.Matches(vaxis.KeyEsc):
+		return "close"
+	default:
+		if key.Keycode >= '1' && key.Keycode <= '9' {
+			idx := int(key.Keycode - '1')
+			if idx < len(v.items) {
+				v.selected = idx
+				return "select"
+			}
+		}
+	}
+	return ""
+}
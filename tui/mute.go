@@ -0,0 +1,180 @@
+package tui
+
+import (
+	"context"
+	"log"
+	"maps"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/AbeEstrada/tuit/config"
+	"github.com/AbeEstrada/tuit/utils"
+	"github.com/mattn/go-mastodon"
+)
+
+// muteRule is a config.ConfigMuteRule ready to be matched
+type muteRule struct {
+	content    *regexp.Regexp
+	language   string
+	domain     string
+	boostsFrom string
+}
+
+// Compiles the mute rules of the config. Rules with an invalid pattern or no
+// field set are left out
+func compileMuteRules(rules []config.ConfigMuteRule, localDomain string) []muteRule {
+	var compiled []muteRule
+	for _, rule := range rules {
+		m := muteRule{
+			language: strings.ToLower(strings.TrimSpace(rule.Language)),
+			domain:   strings.ToLower(strings.TrimPrefix(strings.TrimSpace(rule.Domain), ".")),
+		}
+		if rule.BoostsFrom != "" {
+			m.boostsFrom = normalizeAcct(rule.BoostsFrom, localDomain)
+		}
+		if rule.Content != "" {
+			re, err := regexp.Compile(rule.Content)
+			if err != nil {
+				log.Printf("Invalid mute pattern %q: %v", rule.Content, err)
+				continue
+			}
+			m.content = re
+		}
+		if m.content == nil && m.language == "" && m.domain == "" && m.boostsFrom == "" {
+			continue
+		}
+		compiled = append(compiled, m)
+	}
+	return compiled
+}
+
+// Returns the host of the server URL, such as "example.com"
+func serverDomain(server string) string {
+	u, err := url.Parse(server)
+	if err != nil || u.Host == "" {
+		return strings.ToLower(server)
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// Returns acct as "user@domain", adding the local domain to local accounts
+func normalizeAcct(acct, localDomain string) string {
+	acct = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(acct), "@"))
+	if !strings.Contains(acct, "@") {
+		acct += "@" + localDomain
+	}
+	return acct
+}
+
+func statusText(status *mastodon.Status) string {
+	var text strings.Builder
+	text.WriteString(status.SpoilerText)
+	for _, seg := range utils.ParseStatus(status.Content, status.Tags) {
+		text.WriteString("\n")
+		text.WriteString(seg.Text)
+	}
+	return text.String()
+}
+
+func (r *muteRule) matches(status *mastodon.Status, localDomain string) bool {
+	if r.boostsFrom != "" {
+		if status.Reblog == nil || normalizeAcct(status.Account.Acct, localDomain) != r.boostsFrom {
+			return false
+		}
+	}
+
+	display := status
+	if status.Reblog != nil {
+		display = status.Reblog
+	}
+	if r.language != "" && !strings.EqualFold(display.Language, r.language) {
+		return false
+	}
+	if r.domain != "" {
+		_, domain, _ := strings.Cut(normalizeAcct(display.Account.Acct, localDomain), "@")
+		if domain != r.domain && !strings.HasSuffix(domain, "."+r.domain) {
+			return false
+		}
+	}
+	if r.content != nil && !r.content.MatchString(statusText(display)) {
+		return false
+	}
+	return true
+}
+
+// Reports whether the local mute rules hide the item
+func (app *App) Muted(item TimelineItem) bool {
+	status, ok := item.(StatusItem)
+	if !ok || status.Status == nil {
+		return false
+	}
+
+	for i := range app.muteRules {
+		if app.muteRules[i].matches(status.Status, app.localDomain) {
+			return true
+		}
+	}
+
+	if app.config.Mute.HideRepliesToUnfollowed {
+		display := status.Status
+		if display.Reblog != nil {
+			display = display.Reblog
+		}
+		if replyTo, ok := display.InReplyToAccountID.(string); ok && replyTo != "" {
+			id := mastodon.ID(replyTo)
+			// Our own replies are kept, as are those in a thread with oneself
+			if display.Account.ID != app.accountID && id != display.Account.ID && !app.Follows(id) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Reports whether we follow the account, or are the account. Accounts are
+// taken as followed until the accounts we follow have loaded
+func (app *App) Follows(id mastodon.ID) bool {
+	app.relationshipMu.RLock()
+	defer app.relationshipMu.RUnlock()
+	return app.followsLocked(id)
+}
+
+// Follows, with relationshipMu held
+func (app *App) followsLocked(id mastodon.ID) bool {
+	if relationship := app.relationships[id]; relationship != nil {
+		return relationship.Following
+	}
+	return app.following == nil || app.following[id] || id == app.accountID
+}
+
+// Loads every account we follow, for the rule that hides replies to others
+func (app *App) fetchFollowing(id mastodon.ID) {
+	following := make(map[mastodon.ID]bool)
+	var maxID mastodon.ID
+	pg := &mastodon.Pagination{Limit: 80}
+	for {
+		accounts, err := app.client.GetAccountFollowing(context.Background(), id, pg)
+		if err != nil {
+			log.Printf("Failed to fetch followed accounts: %v", err)
+			return
+		}
+		for _, account := range accounts {
+			following[account.ID] = true
+		}
+		// The Link header leaves the pagination as it was on the last page
+		if len(accounts) == 0 || pg.MaxID == "" || pg.MaxID == maxID {
+			break
+		}
+		maxID = pg.MaxID
+		pg = &mastodon.Pagination{MaxID: maxID, Limit: 80}
+	}
+
+	app.relationshipMu.Lock()
+	changed := app.following == nil || !maps.Equal(app.following, following)
+	app.following = following
+	app.relationshipMu.Unlock()
+	if changed {
+		app.followsChanged()
+	}
+}
@@ -0,0 +1,116 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/AbeEstrada/tuit/config"
+	"github.com/mattn/go-mastodon"
+)
+
+func TestCompileMuteRules(t *testing.T) {
+	tests := []struct {
+		name string
+		rule config.ConfigMuteRule
+		want *muteRule
+	}{
+		{"empty rule", config.ConfigMuteRule{}, nil},
+		{"blank fields", config.ConfigMuteRule{Language: " ", Domain: " "}, nil},
+		{"invalid pattern", config.ConfigMuteRule{Content: "(", Language: "de"}, nil},
+		{"language", config.ConfigMuteRule{Language: " DE "}, &muteRule{language: "de"}},
+		{"domain", config.ConfigMuteRule{Domain: ".Example.COM"}, &muteRule{domain: "example.com"}},
+		{"remote boosts", config.ConfigMuteRule{BoostsFrom: "@Bot@Other.org"}, &muteRule{boostsFrom: "bot@other.org"}},
+		{"local boosts", config.ConfigMuteRule{BoostsFrom: "bot"}, &muteRule{boostsFrom: "bot@local.test"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := compileMuteRules([]config.ConfigMuteRule{tt.rule}, "local.test")
+			if tt.want == nil {
+				if len(rules) != 0 {
+					t.Fatalf("rules = %+v, want none", rules)
+				}
+				return
+			}
+			if len(rules) != 1 {
+				t.Fatalf("got %d rules, want 1", len(rules))
+			}
+			if rules[0] != *tt.want {
+				t.Errorf("rule = %+v, want %+v", rules[0], *tt.want)
+			}
+		})
+	}
+
+	rules := compileMuteRules([]config.ConfigMuteRule{{Content: `(?i)spoiler`}}, "local.test")
+	if len(rules) != 1 || rules[0].content == nil || !rules[0].content.MatchString("SPOILER") {
+		t.Errorf("content rule = %+v, want a compiled pattern", rules)
+	}
+}
+
+func TestMuteRuleMatches(t *testing.T) {
+	status := func(acct, language, content string) *mastodon.Status {
+		return &mastodon.Status{
+			Account:  mastodon.Account{Acct: acct},
+			Language: language,
+			Content:  content,
+		}
+	}
+	boost := func(by string, reblog *mastodon.Status) *mastodon.Status {
+		return &mastodon.Status{Account: mastodon.Account{Acct: by}, Reblog: reblog}
+	}
+
+	tests := []struct {
+		name   string
+		rule   config.ConfigMuteRule
+		status *mastodon.Status
+		want   bool
+	}{
+		{"content", config.ConfigMuteRule{Content: "election"}, status("a@x.org", "en", "<p>The election</p>"), true},
+		{"content missing", config.ConfigMuteRule{Content: "election"}, status("a@x.org", "en", "<p>Cats</p>"), false},
+		{
+			"content warning",
+			config.ConfigMuteRule{Content: "election"},
+			&mastodon.Status{SpoilerText: "election talk", Content: "<p>Cats</p>"},
+			true,
+		},
+		{"content of a boost", config.ConfigMuteRule{Content: "election"}, boost("b", status("a@x.org", "en", "<p>election</p>")), true},
+		{"language", config.ConfigMuteRule{Language: "de"}, status("a@x.org", "DE", ""), true},
+		{"other language", config.ConfigMuteRule{Language: "de"}, status("a@x.org", "en", ""), false},
+		{"domain", config.ConfigMuteRule{Domain: "x.org"}, status("a@x.org", "", ""), true},
+		{"subdomain", config.ConfigMuteRule{Domain: "x.org"}, status("a@social.x.org", "", ""), true},
+		{"suffix of another domain", config.ConfigMuteRule{Domain: "x.org"}, status("a@box.org", "", ""), false},
+		{"local account", config.ConfigMuteRule{Domain: "local.test"}, status("a", "", ""), true},
+		{"domain of a boost", config.ConfigMuteRule{Domain: "x.org"}, boost("b@y.org", status("a@x.org", "", "")), true},
+		{"boosts from", config.ConfigMuteRule{BoostsFrom: "bot@y.org"}, boost("Bot@y.org", status("a@x.org", "", "")), true},
+		{"boosts from a local account", config.ConfigMuteRule{BoostsFrom: "bot"}, boost("bot", status("a@x.org", "", "")), true},
+		{"post by a boosts from account", config.ConfigMuteRule{BoostsFrom: "bot@y.org"}, status("bot@y.org", "", ""), false},
+		{"boost by someone else", config.ConfigMuteRule{BoostsFrom: "bot@y.org"}, boost("c@y.org", status("a@x.org", "", "")), false},
+		{"every field", config.ConfigMuteRule{Language: "de", Domain: "x.org"}, status("a@x.org", "de", ""), true},
+		{"one field differs", config.ConfigMuteRule{Language: "de", Domain: "x.org"}, status("a@x.org", "en", ""), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := compileMuteRules([]config.ConfigMuteRule{tt.rule}, "local.test")
+			if len(rules) != 1 {
+				t.Fatalf("got %d rules, want 1", len(rules))
+			}
+			if got := rules[0].matches(tt.status, "local.test"); got != tt.want {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerDomain(t *testing.T) {
+	tests := []struct {
+		server string
+		want   string
+	}{
+		{"https://Mastodon.Social", "mastodon.social"},
+		{"https://example.com:8443/", "example.com"},
+		{"example.com", "example.com"},
+	}
+	for _, tt := range tests {
+		if got := serverDomain(tt.server); got != tt.want {
+			t.Errorf("serverDomain(%q) = %q, want %q", tt.server, got, tt.want)
+		}
+	}
+}
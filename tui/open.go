@@ -0,0 +1,139 @@
+package tui
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"git.sr.ht/~rockorager/vaxis"
+	"github.com/AbeEstrada/tuit/config"
+	"github.com/AbeEstrada/tuit/utils"
+)
+
+// mediaHandler is a config.ConfigHandler with its pattern compiled
+type mediaHandler struct {
+	config.ConfigHandler
+	pattern *regexp.Regexp
+}
+
+// Compiles the patterns of the configured handlers. An invalid pattern is an
+// error, so it is reported at startup rather than when a URL is opened
+func compileHandlers(handlers []config.ConfigHandler) ([]mediaHandler, error) {
+	compiled := make([]mediaHandler, 0, len(handlers))
+	for _, handler := range handlers {
+		h := mediaHandler{ConfigHandler: handler}
+		if handler.Pattern != "" {
+			re, err := regexp.Compile(handler.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q of media handler %q: %w", handler.Pattern, handler.Command, err)
+			}
+			h.pattern = re
+		}
+		compiled = append(compiled, h)
+	}
+	return compiled, nil
+}
+
+// Reports whether the handler applies to a URL of the given attachment type.
+// Types match the attachment type, the MIME type guessed from the URL, or its
+// major type with a "/*" wildcard
+func handlerMatches(handler mediaHandler, url, kind string) bool {
+	if len(handler.Types) > 0 {
+		mimeType := mime.TypeByExtension(path.Ext(urlPath(url)))
+		mimeType, _, _ = strings.Cut(mimeType, ";")
+		major, _, _ := strings.Cut(mimeType, "/")
+
+		matched := slices.ContainsFunc(handler.Types, func(t string) bool {
+			switch {
+			case t == "":
+				return false
+			case kind != "" && t == kind:
+				return true
+			case mimeType != "" && t == mimeType:
+				return true
+			default:
+				return major != "" && t == major+"/*"
+			}
+		})
+		if !matched {
+			return false
+		}
+	}
+
+	return handler.pattern == nil || handler.pattern.MatchString(url)
+}
+
+// Opens a URL with the first configured handler that matches it, or with the
+// system opener if none does. kind is the attachment type of the URL, empty
+// for plain links
+func (app *App) OpenURL(url, kind string) {
+	for _, handler := range app.handlers {
+		if !handlerMatches(handler, url, kind) {
+			continue
+		}
+		if err := app.runHandler(handler.ConfigHandler, url); err != nil {
+			log.Printf("Failed to run %q: %v", handler.Command, err)
+			app.footer.SetText("Failed to run " + handler.Command)
+		}
+		return
+	}
+
+	if err := utils.OpenBrowser(url); err != nil {
+		log.Printf("Failed to open URL: %v", err)
+	}
+}
+
+func (app *App) runHandler(handler config.ConfigHandler, url string) error {
+	args, err := utils.SplitCommand(handler.Command)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return utils.OpenBrowser(url)
+	}
+
+	replaced := false
+	for i, arg := range args {
+		if strings.Contains(arg, "{url}") {
+			args[i] = strings.ReplaceAll(arg, "{url}", url)
+			replaced = true
+		}
+	}
+	if !replaced {
+		args = append(args, url)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+
+	if !handler.Suspend {
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		go cmd.Wait()
+		return nil
+	}
+
+	return app.runSuspended(cmd)
+}
+
+// Runs a command in the terminal, suspending the interface until it exits
+func (app *App) runSuspended(cmd *exec.Cmd) error {
+	if err := app.vx.Suspend(); err != nil {
+		return err
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	runErr := cmd.Run()
+	if err := app.vx.Resume(); err != nil {
+		return err
+	}
+	app.vx.PostEvent(vaxis.Redraw{})
+	return runErr
+}
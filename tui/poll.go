@@ -0,0 +1,131 @@
+package tui
+
+import (
+	"context"
+	"log"
+	"slices"
+	"time"
+
+	"git.sr.ht/~rockorager/vaxis"
+	"github.com/mattn/go-mastodon"
+)
+
+// Reports whether a poll no longer takes votes
+func pollClosed(poll *mastodon.Poll) bool {
+	return poll.Expired || (!poll.ExpiresAt.IsZero() && poll.ExpiresAt.Before(time.Now()))
+}
+
+// Returns the count the percentages of a poll are taken from. People can pick
+// several options of a multiple choice poll, so those count voters
+func pollTotal(poll *mastodon.Poll) int64 {
+	if poll.Multiple && poll.VotersCount > 0 {
+		return poll.VotersCount
+	}
+	return poll.VotesCount
+}
+
+// Reports whether the view handles every key itself, while voting
+func (v *StatusView) capturesKeys() bool {
+	return v.voting
+}
+
+// Starts voting in the poll of the status shown, or reloads the results of a
+// poll we cannot vote in
+func (v *StatusView) startVoting() {
+	status := v.status
+	if status == nil || status.Poll == nil || v.contentHidden(status) {
+		return
+	}
+	if status.Poll.Voted || pollClosed(status.Poll) {
+		go v.refreshPoll(status)
+		return
+	}
+	v.voting = true
+	v.pollChoices = make(map[int]bool)
+	v.pollCursor = 0
+}
+
+func (v *StatusView) handlePollKey(key vaxis.Key) {
+	status := v.status
+	if status == nil || status.Poll == nil {
+		v.voting = false
+		return
+	}
+	poll := status.Poll
+
+	switch {
+	case key.Matches('j'), key.Matches(vaxis.KeyDown):
+		if v.pollCursor < len(poll.Options)-1 {
+			v.pollCursor++
+		}
+	case key.Matches('k'), key.Matches(vaxis.KeyUp):
+		if v.pollCursor > 0 {
+			v.pollCursor--
+		}
+	case key.Matches(vaxis.KeySpace):
+		if poll.Multiple {
+			v.pollChoices[v.pollCursor] = !v.pollChoices[v.pollCursor]
+		} else {
+			v.pollChoices = map[int]bool{v.pollCursor: true}
+		}
+	case key.Matches(vaxis.KeyEnter):
+		if !poll.Multiple {
+			// A single choice is the option under the cursor
+			v.pollChoices = map[int]bool{v.pollCursor: true}
+		}
+		var choices []int
+		for choice, picked := range v.pollChoices {
+			if picked {
+				choices = append(choices, choice)
+			}
+		}
+		if len(choices) == 0 {
+			v.app.footer.SetText("Choose at least one option")
+			return
+		}
+		slices.Sort(choices)
+		v.voting = false
+		go v.vote(status, choices)
+	case key.Matches(vaxis.KeyEsc):
+		v.voting = false
+	}
+}
+
+func (v *StatusView) vote(status *mastodon.Status, choices []int) {
+	poll, err := v.app.client.PollVote(context.Background(), status.Poll.ID, choices...)
+	if err != nil {
+		log.Printf("Failed to vote: %v", err)
+	}
+	v.app.RunOnMain(func() {
+		if err != nil {
+			v.app.footer.SetText("Failed to vote")
+			return
+		}
+		v.app.footer.SetText("Voted")
+		v.updatePoll(status, poll)
+	})
+}
+
+func (v *StatusView) refreshPoll(status *mastodon.Status) {
+	poll, err := v.app.client.GetPoll(context.Background(), status.Poll.ID)
+	if err != nil {
+		log.Printf("Failed to fetch poll: %v", err)
+	}
+	v.app.RunOnMain(func() {
+		if err != nil {
+			v.app.footer.SetText("Failed to refresh poll")
+			return
+		}
+		v.updatePoll(status, poll)
+	})
+}
+
+// Replaces the poll of a status wherever the status is shown. Called on the
+// main loop
+func (v *StatusView) updatePoll(status *mastodon.Status, poll *mastodon.Poll) {
+	updated := *status
+	updated.Poll = poll
+	if v.onUpdate != nil {
+		v.onUpdate(&updated)
+	}
+}
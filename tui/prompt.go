@@ -0,0 +1,78 @@
+package tui
+
+import (
+	"git.sr.ht/~rockorager/vaxis"
+	"git.sr.ht/~rockorager/vaxis/widgets/textinput"
+	"github.com/AbeEstrada/tuit/utils"
+)
+
+// Prompt asks for a line of text in place of the footer
+type Prompt struct {
+	input    *textinput.Model
+	onSubmit func(text string)
+	// complete returns the texts Tab can complete the text to, if set
+	complete func(text string) []string
+	// Completions that repeated Tabs go through, when none was longer
+	candidates []string
+	candidate  int
+}
+
+func CreatePrompt(label, text string, onSubmit func(text string)) *Prompt {
+	input := textinput.New().SetPrompt(label + ": ").SetContent(text)
+	input.Prompt = vaxis.Style{Attribute: vaxis.AttrBold}
+	return &Prompt{
+		input:    input,
+		onSubmit: onSubmit,
+	}
+}
+
+func (p *Prompt) Draw(win vaxis.Window) {
+	_, height := win.Size()
+	p.input.Draw(win.New(0, height-1, -1, 1))
+}
+
+// Completes the text as far as every completion agrees, then goes through
+// the completions one at a time
+func (p *Prompt) completeText() {
+	if len(p.candidates) > 0 {
+		p.candidate = (p.candidate + 1) % len(p.candidates)
+		p.input.SetContent(p.candidates[p.candidate])
+		return
+	}
+
+	text := p.input.String()
+	matches := p.complete(text)
+	if len(matches) == 0 {
+		return
+	}
+	if prefix := utils.CommonPrefix(matches); len(prefix) > len(text) {
+		p.input.SetContent(prefix)
+		return
+	}
+	p.candidates = matches
+	p.candidate = 0
+	p.input.SetContent(matches[0])
+}
+
+// Returns "submit" when the text is entered and "close" when the prompt is
+// dismissed
+func (p *Prompt) HandleEvent(event vaxis.Event) string {
+	if key, ok := event.(vaxis.Key); ok && key.EventType != vaxis.EventPaste {
+		switch {
+		case key.Matches(vaxis.KeyEnter):
+			return "submit"
+		case key.Matches(vaxis.KeyEsc):
+			return "close"
+		case key.Matches(vaxis.KeyTab) && p.complete != nil:
+			p.completeText()
+			return ""
+		}
+	}
+	p.candidates = nil
+	p.input.Update(event)
+	return ""
+}
+
+func (p *Prompt) String() string {
+	return p.input.String()
+}
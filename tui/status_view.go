@@ -5,6 +5,7 @@ import (
 	"slices"
 
 	"git.sr.ht/~rockorager/vaxis"
+	"github.com/AbeEstrada/tuit/config"
 	"github.com/AbeEstrada/tuit/utils"
 	"github.com/mattn/go-mastodon"
 )
@@ -14,26 +15,121 @@ type StatusView struct {
 	scrollOffset int
 	totalHeight  int
 	viewHeight   int
+	revealed     map[mastodon.ID]bool
+	player       *animationPlayer
+	// Status last drawn, whose poll the keys vote in
+	status *mastodon.Status
+	// Voting in the poll of the status, with the options picked and the one
+	// under the cursor
+	voting      bool
+	pollChoices map[int]bool
+	pollCursor  int
+	onUpdate    func(status *mastodon.Status)
 }
 
 func CreateStatusView() *StatusView {
-	return &StatusView{}
+	return &StatusView{
+		revealed: make(map[mastodon.ID]bool),
+	}
 }
 
 func (v *StatusView) SetApp(app *App) {
 	v.app = app
+	v.player = newAnimationPlayer(app.vx)
+}
+
+// Reports whether the content of a status is collapsed behind its content warning
+func (v *StatusView) contentHidden(status *mastodon.Status) bool {
+	if status.SpoilerText == "" {
+		return false
+	}
+	if revealed, ok := v.revealed[status.ID]; ok {
+		return !revealed
+	}
+	return !v.app.config.Reading.ExpandSpoilers
+}
+
+// Reports whether the media attachments of a status are hidden
+func (v *StatusView) mediaHidden(status *mastodon.Status) bool {
+	if len(status.MediaAttachments) == 0 {
+		return false
+	}
+	if revealed, ok := v.revealed[status.ID]; ok {
+		return !revealed
+	}
+	switch v.app.config.Reading.ExpandMedia {
+	case config.ExpandMediaShowAll:
+		return false
+	case config.ExpandMediaHideAll:
+		return true
+	default:
+		return status.Sensitive
+	}
+}
+
+// Reveals the hidden content and media of a status, or hides them again if
+// everything is already shown. The state is remembered per status
+func (v *StatusView) ToggleReveal(status *mastodon.Status) {
+	if status == nil {
+		return
+	}
+	if status.Reblog != nil {
+		status = status.Reblog
+	}
+	v.revealed[status.ID] = v.contentHidden(status) || v.mediaHidden(status)
 }
 
 func imageVisible(screenY, imgHeight, winHeight int) bool {
 	return screenY >= 0 && screenY+imgHeight <= winHeight
 }
 
-func (v *StatusView) Draw(win vaxis.Window, focused bool, status *mastodon.Status) {
+// Returns the number of rows reserved for media of the given size in pixels
+// when drawn mediaWidth cells wide
+func reservedMediaHeight(mediaWidth int, width, height int64) int {
+	if width <= 0 {
+		return 10
+	}
+	aspectRatio := float64(height) / float64(width)
+	return max(int(float64(mediaWidth)*aspectRatio*0.5), 1)
+}
+
+// Returns the text shown in place of a media attachment
+func mediaLabel(media mastodon.Attachment) string {
+	label := media.Type
+	if media.Description != "" {
+		label += ": " + media.Description
+	}
+	return label
+}
+
+// Draws the blurhash of a media attachment as a placeholder of the given size
+func drawBlurhash(win vaxis.Window, hash string, screenY, width, height, winHeight int) {
+	if !imageVisible(screenY, height, winHeight) {
+		return
+	}
+	if img, ok := utils.ImageCache.GetBlurhash(hash, width, height); ok {
+		img.Draw(win.New(0, screenY, width, height))
+	}
+}
+
+// Draws a status. filtered is the title of the filter that collapses it, if
+// any, in which case only the filter is shown
+func (v *StatusView) Draw(win vaxis.Window, focused bool, status *mastodon.Status, filtered string) {
 	if status == nil {
+		v.status = nil
+		v.voting = false
 		v.totalHeight = 0
 		win.Println(0, vaxis.Segment{Text: ""})
 		return
 	}
+	if filtered != "" {
+		v.status = nil
+		v.voting = false
+		win.Println(0, vaxis.Segment{Text: "Filtered: " + filtered, Style: vaxis.Style{Attribute: vaxis.AttrBold}})
+		win.Println(1, vaxis.Segment{Text: "Press z to show anyway", Style: vaxis.Style{Attribute: vaxis.AttrDim}})
+		v.totalHeight = 2
+		return
+	}
 
 	width, height := win.Size()
 	height = height - 1
@@ -72,13 +168,33 @@ func (v *StatusView) Draw(win vaxis.Window, focused bool, status *mastodon.Statu
 	}
 
 	metaX := avatarWidth + 1
+	emojis := v.app.Emojis(displayStatus.Emojis, displayStatus.Account.Emojis)
 
 	var isBot string
 	if displayStatus.Account.Bot {
 		isBot = " · Automated"
 	}
+	var note string
+	if relationship := v.app.Relationship(displayStatus.Account.ID); relationship != nil && relationship.Note != "" {
+		note = " " + noteIndicator
+	}
 	timeLine := fmt.Sprintf("%s · %s", utils.FormatTimeSince(displayStatus.CreatedAt.Local()), utils.TitleCase(displayStatus.Visibility))
+	if !displayStatus.EditedAt.IsZero() {
+		timeLine += " · Edited"
+	}
 	statsLine := fmt.Sprintf("%d replies · %d boosts · %d favorites", displayStatus.RepliesCount, displayStatus.ReblogsCount, displayStatus.FavouritesCount)
+	if favourited, _ := displayStatus.Favourited.(bool); favourited {
+		statsLine += " · ★ Favorited"
+	}
+	if bookmarked, _ := displayStatus.Bookmarked.(bool); bookmarked {
+		statsLine += " · Bookmarked"
+	}
+	if pinned, _ := displayStatus.Pinned.(bool); pinned {
+		statsLine += " · Pinned"
+	}
+	if muted, _ := displayStatus.Muted.(bool); muted {
+		statsLine += " · Muted"
+	}
 
 	for row := 0; row < avatarHeight; row++ {
 		screenRow := screenHeaderY + row
@@ -88,7 +204,7 @@ func (v *StatusView) Draw(win vaxis.Window, focused bool, status *mastodon.Statu
 		lineWin := win.New(metaX, screenRow, width-metaX, 1)
 		switch row {
 		case 0:
-			lineWin.Println(0,
+			printText(lineWin, 0, emojis,
 				vaxis.Segment{
 					Text:  displayStatus.Account.DisplayName,
 					Style: vaxis.Style{Attribute: vaxis.AttrBold},
@@ -98,6 +214,7 @@ func (v *StatusView) Draw(win vaxis.Window, focused bool, status *mastodon.Statu
 					Style: vaxis.Style{Attribute: vaxis.AttrBold},
 				},
 				vaxis.Segment{Text: isBot},
+				vaxis.Segment{Text: note, Style: vaxis.Style{Attribute: vaxis.AttrDim}},
 			)
 		case 1:
 			lineWin.Println(0, vaxis.Segment{Text: timeLine})
@@ -108,62 +225,121 @@ func (v *StatusView) Draw(win vaxis.Window, focused bool, status *mastodon.Statu
 
 	y = headerY + avatarHeight + 1
 
-	if displayStatus.Sensitive {
+	if v.status == nil || v.status.ID != displayStatus.ID {
+		v.voting = false
+	}
+	v.status = displayStatus
+
+	contentHidden := v.contentHidden(displayStatus)
+	mediaHidden := v.mediaHidden(displayStatus)
+	hintStyle := vaxis.Style{Attribute: vaxis.AttrDim}
+
+	if displayStatus.SpoilerText != "" {
+		spoiler := vaxis.Segment{
+			Text:  "⚠ " + displayStatus.SpoilerText + "\n",
+			Style: vaxis.Style{Attribute: vaxis.AttrBold},
+		}
+		_, spoilerRows := wrapText(win.New(0, -height*4, width, height*4), emojis, spoiler)
+		wrapText(win.New(0, y-so, width, height*4), emojis, spoiler)
+		y += spoilerRows
+
+		hint := "Press z to show less"
+		if contentHidden {
+			hint = "Press z to show more"
+		}
+		win.Println(y-so, vaxis.Segment{Text: hint, Style: hintStyle})
+		y += 2
+	} else if displayStatus.Sensitive {
 		win.Println(y-so, vaxis.Segment{Text: "⚠ Sensitive"})
 		y += 2
 	}
 
-	content := utils.ParseStatus(displayStatus.Content, displayStatus.Tags)
-	measureWin := win.New(0, -height*2, width, height*4)
-	_, rows := measureWin.Wrap(content...)
+	var content []vaxis.Segment
+	if !contentHidden {
+		content = utils.ParseStatus(displayStatus.Content, displayStatus.Tags)
+	}
+	measureWin := win.New(0, -height*4, width, height*4)
+	_, rows := wrapText(measureWin, emojis, content...)
 
 	screenContentY := y - so
 	contentWin := win.New(0, screenContentY, width, height*4)
-	contentWin.Wrap(content...)
+	wrapText(contentWin, emojis, content...)
 
 	// contentY tracks logical rows within the content area (after text)
 	contentY := rows
 
-	if displayStatus.Poll != nil {
+	if displayStatus.Poll != nil && !contentHidden {
 		poll := displayStatus.Poll
+		voting := v.voting && focused
+		closed := pollClosed(poll)
+		showResults := poll.Voted || closed
 
 		contentWin.Println(contentY, vaxis.Segment{Text: "Poll"})
 		contentY++
 
-		indicator := "○"
-		if poll.Multiple {
-			indicator = "☐"
-		}
-
 		for i, option := range poll.Options {
-			prefix := indicator
-			if slices.Contains(poll.OwnVotes, i) {
+			prefix := "○"
+			if poll.Multiple {
+				prefix = "☐"
+			}
+			if voting && v.pollChoices[i] {
+				prefix = "●"
+				if poll.Multiple {
+					prefix = "☑"
+				}
+			} else if slices.Contains(poll.OwnVotes, i) {
 				prefix = "✓"
 			}
 
 			var votes string
-			if poll.Voted {
+			if showResults {
 				percentage := 0.0
-				if poll.VotersCount > 0 {
-					percentage = float64(option.VotesCount) / float64(poll.VotersCount) * 100
+				if total := pollTotal(poll); total > 0 {
+					percentage = float64(option.VotesCount) / float64(total) * 100
 				}
-				votes = fmt.Sprintf("%.0f%%", percentage)
+				votes = fmt.Sprintf("%.0f%% ", percentage)
 			}
 
-			formatted := fmt.Sprintf("%s %s %s", prefix, votes, option.Title)
-			contentWin.Println(contentY, vaxis.Segment{Text: formatted})
+			var style vaxis.Style
+			if voting && i == v.pollCursor {
+				style.Attribute = vaxis.AttrReverse
+			}
+			formatted := fmt.Sprintf("%s %s%s", prefix, votes, option.Title)
+			contentWin.Println(contentY, vaxis.Segment{Text: formatted, Style: style})
 			contentY++
 		}
 
-		if poll.Voted {
-			contentWin.Println(contentY, vaxis.Segment{Text: fmt.Sprintf("Total: %d", poll.VotersCount)})
+		summary := utils.FormatTimeLeft(poll.ExpiresAt)
+		if closed {
+			summary = "Closed"
+		} else if poll.ExpiresAt.IsZero() {
+			summary = "Open"
+		}
+		if showResults {
+			summary = fmt.Sprintf("Total: %d · %s", poll.VotersCount, summary)
+		}
+		contentWin.Println(contentY, vaxis.Segment{Text: summary})
+		contentY++
+
+		switch {
+		case voting && poll.Multiple:
+			contentWin.Println(contentY, vaxis.Segment{Text: "Space choose · Enter vote · Esc cancel", Style: hintStyle})
+			contentY++
+		case voting:
+			contentWin.Println(contentY, vaxis.Segment{Text: "Enter vote · Esc cancel", Style: hintStyle})
+			contentY++
+		case focused && !showResults:
+			contentWin.Println(contentY, vaxis.Segment{Text: "Press p to vote", Style: hintStyle})
+			contentY++
+		case focused:
+			contentWin.Println(contentY, vaxis.Segment{Text: "Press p to refresh results", Style: hintStyle})
 			contentY++
 		}
 
 		contentY += 2
 	}
 
-	if displayStatus.Card != nil {
+	if displayStatus.Card != nil && !contentHidden {
 		card := displayStatus.Card
 
 		if card.URL != "" {
@@ -189,15 +365,7 @@ func (v *StatusView) Draw(win vaxis.Window, focused bool, status *mastodon.Statu
 		if card.Image != "" {
 			imageURL := card.Image
 			mediaWidth := width
-			var calculatedHeight int
-
-			if card.Width > 0 {
-				aspectRatio := float64(card.Height) / float64(card.Width)
-				calculatedHeight = int(float64(mediaWidth) * aspectRatio * 0.5)
-				calculatedHeight = max(calculatedHeight, 1)
-			} else {
-				calculatedHeight = 10
-			}
+			calculatedHeight := reservedMediaHeight(mediaWidth, card.Width, card.Height)
 
 			vxImage, cached := utils.ImageCache.Get(imageURL, mediaWidth, calculatedHeight)
 			if cached {
@@ -218,10 +386,36 @@ func (v *StatusView) Draw(win vaxis.Window, focused bool, status *mastodon.Statu
 		contentY++
 	}
 
-	if len(displayStatus.MediaAttachments) > 0 {
+	if len(displayStatus.MediaAttachments) > 0 && !contentHidden && mediaHidden {
 		mediaWidth := width
+		imagesEnabled := utils.ImageCache.Enabled()
+
+		for _, media := range displayStatus.MediaAttachments {
+			contentWin.PrintTruncate(contentY, vaxis.Segment{Text: "▣ Hidden " + mediaLabel(media)})
+			contentY++
+
+			if media.BlurHash != "" && imagesEnabled {
+				imageMeta := media.Meta.Original
+				calculatedHeight := reservedMediaHeight(mediaWidth, imageMeta.Width, imageMeta.Height)
+				drawBlurhash(win, media.BlurHash, screenContentY+contentY, mediaWidth, calculatedHeight, height)
+				contentY += calculatedHeight + 1
+			}
+		}
+		contentWin.Println(contentY, vaxis.Segment{Text: "Press z to show media", Style: hintStyle})
+		contentY += 2
+	} else if len(displayStatus.MediaAttachments) > 0 && !contentHidden {
+		mediaWidth := width
+
+		imagesEnabled := utils.ImageCache.Enabled()
 
 		for i, media := range displayStatus.MediaAttachments {
+			if !imagesEnabled {
+				// Without images, list the attachments with their descriptions
+				contentWin.PrintTruncate(contentY, vaxis.Segment{Text: "▣ " + mediaLabel(media)})
+				contentY++
+				continue
+			}
+
 			if i > 0 {
 				contentY++
 			}
@@ -242,15 +436,29 @@ func (v *StatusView) Draw(win vaxis.Window, focused bool, status *mastodon.Statu
 				continue
 			}
 
-			var calculatedHeight int
 			imageMeta := media.Meta.Original
+			calculatedHeight := reservedMediaHeight(mediaWidth, imageMeta.Width, imageMeta.Height)
 
-			if imageMeta.Width > 0 {
-				aspectRatio := float64(imageMeta.Height) / float64(imageMeta.Width)
-				calculatedHeight = int(float64(mediaWidth) * aspectRatio * 0.5)
-				calculatedHeight = max(calculatedHeight, 1)
-			} else {
-				calculatedHeight = 10
+			var animURL string
+			if !v.app.config.Images.ReducedMotion {
+				animURL = animationURL(media)
+			}
+			if animURL != "" {
+				// The preview is shown until the original has loaded, and
+				// stays if it fails to
+				if anim, ok := utils.ImageCache.GetAnimation(animURL, mediaWidth, calculatedHeight); ok {
+					_, mediaHeight := anim.CellSize()
+					imgScreenY := screenContentY + contentY
+
+					if imageVisible(imgScreenY, mediaHeight, height) {
+						v.player.Advance(animURL, anim)
+						imgWin := win.New(0, imgScreenY, mediaWidth, mediaHeight)
+						anim.Draw(imgWin)
+					}
+
+					contentY += mediaHeight
+					continue
+				}
 			}
 
 			vxImage, cached := utils.ImageCache.Get(imageURL, mediaWidth, calculatedHeight)
@@ -266,11 +474,17 @@ func (v *StatusView) Draw(win vaxis.Window, focused bool, status *mastodon.Statu
 				contentY += mediaHeight
 			} else {
 				utils.ImageCache.LoadAsync(imageURL)
+				if media.BlurHash != "" {
+					// Keep the space of the image while it loads
+					drawBlurhash(win, media.BlurHash, screenContentY+contentY, mediaWidth, calculatedHeight, height)
+					contentY += calculatedHeight
+				}
 			}
 		}
 		contentY++
 	}
 
+	v.player.Schedule()
 	v.totalHeight = y + contentY
 }
 
@@ -279,6 +493,14 @@ func (v *StatusView) ResetScroll() {
 }
 
 func (v *StatusView) HandleKey(key vaxis.Key) {
+	if v.voting {
+		v.handlePollKey(key)
+		return
+	}
+	if key.Matches('p') {
+		v.startVoting()
+		return
+	}
 	if v.totalHeight <= v.viewHeight {
 		return
 	}
@@ -0,0 +1,146 @@
+package tui
+
+import (
+	"git.sr.ht/~rockorager/vaxis"
+	"github.com/AbeEstrada/tuit/utils"
+	"github.com/rivo/uniseg"
+)
+
+// Width in cells of an inline custom emoji image
+const emojiWidth = 2
+
+// Reports whether the cell at col, row of the window ends up on screen
+func cellVisible(win vaxis.Window, col, row int) bool {
+	for {
+		if col < 0 || row < 0 || col >= win.Width || row >= win.Height {
+			return false
+		}
+		if win.Parent == nil {
+			return true
+		}
+		col += win.Column
+		row += win.Row
+		win = *win.Parent
+	}
+}
+
+func hasInlineEmoji(emojis map[string]string, segs []vaxis.Segment) bool {
+	if len(emojis) == 0 || !utils.ImageCache.CanDisplayGraphics() {
+		return false
+	}
+	for _, seg := range segs {
+		if _, ok := emojis[seg.Text]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Draws a custom emoji image at col, row. Nothing is drawn while the image
+// loads, leaving the reserved cells blank
+func drawEmoji(win vaxis.Window, col, row int, url string) {
+	if !cellVisible(win, col, row) || !cellVisible(win, col+emojiWidth-1, row) {
+		return
+	}
+	if img, ok := utils.ImageCache.GetFitted(url, emojiWidth, 1); ok {
+		img.Draw(win.New(col, row, emojiWidth, 1))
+	}
+}
+
+// Wraps segments like vaxis.Window.Wrap. Segments that are a known custom
+// emoji shortcode are drawn as inline images when the terminal supports them,
+// and as their styled text otherwise
+func wrapText(win vaxis.Window, emojis map[string]string, segs ...vaxis.Segment) (col int, row int) {
+	segs = utils.SplitEmoji(segs, emojis)
+	if !hasInlineEmoji(emojis, segs) {
+		return win.Wrap(segs...)
+	}
+
+	cols, rows := win.Size()
+	state := -1
+	for _, seg := range segs {
+		if url, ok := emojis[seg.Text]; ok {
+			if row >= rows {
+				break
+			}
+			if col+emojiWidth > cols {
+				col = 0
+				row += 1
+			}
+			drawEmoji(win, col, row, url)
+			col += emojiWidth
+			continue
+		}
+
+		rest := seg.Text
+		var segment string
+		for len(rest) > 0 {
+			if row >= rows {
+				break
+			}
+			segment, rest, _, state = uniseg.FirstLineSegmentInString(rest, state)
+			chars := vaxis.Characters(segment)
+			total := 0
+			for i, char := range chars {
+				chars[i].Width = win.Vx.RenderedWidth(char.Grapheme)
+				total += chars[i].Width
+			}
+			if total <= cols && total+col > cols {
+				// there isn't space left, go to a new line
+				col = 0
+				row += 1
+			}
+			for _, char := range chars {
+				if uniseg.HasTrailingLineBreakInString(char.Grapheme) {
+					row += 1
+					col = 0
+					continue
+				}
+				win.SetCell(col, row, vaxis.Cell{Character: char, Style: seg.Style})
+				col += char.Width
+				if col >= cols {
+					row += 1
+					col = 0
+				}
+			}
+		}
+	}
+	return col, row
+}
+
+// Prints a single line of segments like vaxis.Window.PrintTruncate, drawing
+// custom emoji the same way as wrapText
+func printText(win vaxis.Window, row int, emojis map[string]string, segs ...vaxis.Segment) {
+	segs = utils.SplitEmoji(segs, emojis)
+	if !hasInlineEmoji(emojis, segs) {
+		win.PrintTruncate(row, segs...)
+		return
+	}
+
+	cols, rows := win.Size()
+	if row >= rows {
+		return
+	}
+	truncator := vaxis.Character{Grapheme: "…", Width: 1}
+	col := 0
+	for _, seg := range segs {
+		if url, ok := emojis[seg.Text]; ok {
+			if col+emojiWidth+truncator.Width > cols {
+				win.SetCell(col, row, vaxis.Cell{Character: truncator, Style: seg.Style})
+				return
+			}
+			drawEmoji(win, col, row, url)
+			col += emojiWidth
+			continue
+		}
+		for _, char := range vaxis.Characters(seg.Text) {
+			char.Width = win.Vx.RenderedWidth(char.Grapheme)
+			if col+truncator.Width+char.Width > cols {
+				win.SetCell(col, row, vaxis.Cell{Character: truncator, Style: seg.Style})
+				return
+			}
+			win.SetCell(col, row, vaxis.Cell{Character: char, Style: seg.Style})
+			col += char.Width
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package tui
+
+import (
+	"unicode"
+
+	"git.sr.ht/~rockorager/vaxis"
+	"github.com/rivo/uniseg"
+)
+
+// textAreaRow is a wrapped row of a TextArea, as rune offsets into its text.
+// end excludes the newline that ends a line
+type textAreaRow struct {
+	start int
+	end   int
+}
+
+// TextArea is a multi-line text input. Long lines are wrapped at word
+// boundaries to the width of the window it is drawn in
+type TextArea struct {
+	text   []rune
+	cursor int
+	scroll int
+	width  int
+	// Rows of the last layout, used to move the cursor up and down
+	rows []textAreaRow
+	// Column the cursor keeps when moving up and down
+	goalCol int
+}
+
+func CreateTextArea() *TextArea {
+	return &TextArea{goalCol: -1}
+}
+
+// Replaces the text and moves the cursor to its end
+func (t *TextArea) SetText(text string) {
+	t.text = []rune(text)
+	t.cursor = len(t.text)
+	t.scroll = 0
+	t.rows = nil
+	t.goalCol = -1
+}
+
+func (t *TextArea) String() string {
+	return string(t.text)
+}
+
+// Returns the number of characters in the text
+func (t *TextArea) Len() int {
+	return uniseg.GraphemeClusterCount(string(t.text))
+}
+
+func runeWidth(r rune) int {
+	if r == '\t' {
+		return 1
+	}
+	return max(uniseg.StringWidth(string(r)), 0)
+}
+
+// Splits the text into rows of at most width cells
+func (t *TextArea) layout(width int) []textAreaRow {
+	width = max(width, 1)
+	var rows []textAreaRow
+	start, col, lastSpace := 0, 0, -1
+	for i, r := range t.text {
+		if r == '\n' {
+			rows = append(rows, textAreaRow{start, i})
+			start, col, lastSpace = i+1, 0, -1
+			continue
+		}
+		w := runeWidth(r)
+		// Spaces may run past the edge so rows do not start with one
+		if col+w > width && i > start && !unicode.IsSpace(r) {
+			end := i
+			if lastSpace >= start {
+				end = lastSpace + 1
+			}
+			rows = append(rows, textAreaRow{start, end})
+			start, lastSpace = end, -1
+			col = 0
+			for _, c := range t.text[start:i] {
+				col += runeWidth(c)
+			}
+		}
+		if unicode.IsSpace(r) {
+			lastSpace = i
+		}
+		col += w
+	}
+	return append(rows, textAreaRow{start, len(t.text)})
+}
+
+// Returns the row the cursor is on. At a wrap the cursor belongs to the row
+// that starts there
+func (t *TextArea) cursorRow() int {
+	for i, row := range t.rows {
+		if t.cursor < row.end || (t.cursor == row.end && (i == len(t.rows)-1 || t.rows[i+1].start != row.end)) {
+			if t.cursor >= row.start {
+				return i
+			}
+		}
+	}
+	return max(len(t.rows)-1, 0)
+}
+
+func (t *TextArea) columnOf(row textAreaRow, offset int) int {
+	col := 0
+	for _, r := range t.text[row.start:offset] {
+		col += runeWidth(r)
+	}
+	return col
+}
+
+func (t *TextArea) Draw(win vaxis.Window, focused bool) {
+	width, height := win.Size()
+	if width <= 0 || height <= 0 {
+		return
+	}
+	t.width = width
+	t.rows = t.layout(width)
+
+	cursorRow := t.cursorRow()
+	if cursorRow < t.scroll {
+		t.scroll = cursorRow
+	} else if cursorRow >= t.scroll+height {
+		t.scroll = cursorRow - height + 1
+	}
+
+	for y := 0; y < height && t.scroll+y < len(t.rows); y++ {
+		row := t.rows[t.scroll+y]
+		col := 0
+		for _, r := range t.text[row.start:row.end] {
+			w := runeWidth(r)
+			grapheme := string(r)
+			if r == '\t' {
+				grapheme = " "
+			}
+			if w > 0 {
+				win.SetCell(col, y, vaxis.Cell{Character: vaxis.Character{Grapheme: grapheme, Width: w}})
+			}
+			col += w
+		}
+	}
+
+	if focused {
+		col := min(t.columnOf(t.rows[cursorRow], t.cursor), width-1)
+		win.ShowCursor(col, cursorRow-t.scroll, vaxis.CursorBeam)
+	}
+}
+
+func (t *TextArea) insert(s string) {
+	runes := []rune(s)
+	text := make([]rune, 0, len(t.text)+len(runes))
+	text = append(text, t.text[:t.cursor]...)
+	text = append(text, runes...)
+	text = append(text, t.text[t.cursor:]...)
+	t.text = text
+	t.cursor += len(runes)
+}
+
+func (t *TextArea) delete(from, to int) {
+	if from < 0 || to > len(t.text) || from >= to {
+		return
+	}
+	t.text = append(t.text[:from], t.text[to:]...)
+	t.cursor = from
+}
+
+// Moves the cursor to the same column of the row delta rows away
+func (t *TextArea) moveRows(delta int) {
+	if len(t.rows) == 0 {
+		return
+	}
+	current := t.cursorRow()
+	if t.goalCol < 0 {
+		t.goalCol = t.columnOf(t.rows[current], t.cursor)
+	}
+	target := current + delta
+	if target < 0 {
+		t.cursor = 0
+		return
+	}
+	if target >= len(t.rows) {
+		t.cursor = len(t.text)
+		return
+	}
+	row := t.rows[target]
+	t.cursor = row.start
+	col := 0
+	for t.cursor < row.end {
+		w := runeWidth(t.text[t.cursor])
+		if col+w > t.goalCol {
+			break
+		}
+		col += w
+		t.cursor++
+	}
+	// A wrapped row ends where the next starts, keep the cursor on this one
+	if t.cursor == row.end && target < len(t.rows)-1 && t.rows[target+1].start == row.end && t.cursor > row.start {
+		t.cursor--
+	}
+}
+
+// Returns the start of the word before the cursor
+func (t *TextArea) wordStart() int {
+	i := t.cursor
+	for i > 0 && unicode.IsSpace(t.text[i-1]) {
+		i--
+	}
+	for i > 0 && !unicode.IsSpace(t.text[i-1]) {
+		i--
+	}
+	return i
+}
+
+// Handles an editing key and reports whether it was used
+func (t *TextArea) HandleKey(key vaxis.Key) bool {
+	t.rows = t.layout(t.width)
+	keepGoal := false
+	switch {
+	case key.Matches(vaxis.KeyUp):
+		t.moveRows(-1)
+		keepGoal = true
+	case key.Matches(vaxis.KeyDown):
+		t.moveRows(1)
+		keepGoal = true
+	case key.Matches(vaxis.KeyLeft):
+		if t.cursor > 0 {
+			t.cursor--
+		}
+	case key.Matches(vaxis.KeyRight):
+		if t.cursor < len(t.text) {
+			t.cursor++
+		}
+	case key.Matches(vaxis.KeyHome):
+		if len(t.rows) > 0 {
+			t.cursor = t.rows[t.cursorRow()].start
+		}
+	case key.Matches(vaxis.KeyEnd):
+		if len(t.rows) > 0 {
+			current := t.cursorRow()
+			t.cursor = t.rows[current].end
+			// Stay before the space a wrapped row ends with
+			if current < len(t.rows)-1 && t.rows[current+1].start == t.cursor && t.cursor > t.rows[current].start {
+				t.cursor--
+			}
+		}
+	case key.Matches(vaxis.KeyBackspace):
+		t.delete(t.cursor-1, t.cursor)
+	case key.Matches(vaxis.KeyDelete), key.Matches('d', vaxis.ModCtrl):
+		cursor := t.cursor
+		t.delete(t.cursor, t.cursor+1)
+		t.cursor = cursor
+	case key.Matches('w', vaxis.ModCtrl):
+		t.delete(t.wordStart(), t.cursor)
+	case key.Matches('u', vaxis.ModCtrl):
+		if len(t.rows) > 0 {
+			t.delete(t.rows[t.cursorRow()].start, t.cursor)
+		}
+	case key.Matches(vaxis.KeyEnter):
+		t.insert("\n")
+	case key.Text != "" && key.Modifiers&(vaxis.ModCtrl|vaxis.ModAlt|vaxis.ModSuper) == 0:
+		t.insert(key.Text)
+	default:
+		return false
+	}
+	if !keepGoal {
+		t.goalCol = -1
+	}
+	return true
+}
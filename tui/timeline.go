@@ -1,15 +1,110 @@
 package tui
 
-import "github.com/mattn/go-mastodon"
+import (
+	"context"
+	"slices"
+
+	"github.com/AbeEstrada/tuit/api"
+	"github.com/mattn/go-mastodon"
+)
+
+// AccountTab selects which statuses an account timeline shows
+type AccountTab int
+
+const (
+	AccountTabPostsAndReplies AccountTab = iota
+	AccountTabPosts
+	AccountTabMedia
+)
+
+// Account tabs in the order they are shown
+var accountTabs = []AccountTab{AccountTabPosts, AccountTabPostsAndReplies, AccountTabMedia}
+
+func (t AccountTab) String() string {
+	switch t {
+	case AccountTabPosts:
+		return "Posts"
+	case AccountTabMedia:
+		return "Media"
+	default:
+		return "Posts & replies"
+	}
+}
+
+func (t AccountTab) Options() api.AccountStatusesOptions {
+	return api.AccountStatusesOptions{
+		ExcludeReplies: t == AccountTabPosts,
+		OnlyMedia:      t == AccountTabMedia,
+	}
+}
+
+// AccountList selects which accounts related to an account a timeline shows
+type AccountList int
+
+const (
+	AccountListNone AccountList = iota
+	AccountListFollowers
+	AccountListFollowing
+)
+
+func (l AccountList) String() string {
+	switch l {
+	case AccountListFollowers:
+		return "Followers"
+	case AccountListFollowing:
+		return "Following"
+	default:
+		return ""
+	}
+}
+
+// TimelineKind is what a timeline shows
+type TimelineKind int
+
+const (
+	TimelineHome TimelineKind = iota
+	TimelineThread
+	// The statuses of Account
+	TimelineAccount
+	// The followers or followed accounts of Account, as AccountList says
+	TimelineAccountList
+	// Our lists, and the statuses of List
+	TimelineLists
+	TimelineList
+	// Our server filters
+	TimelineFilters
+	TimelineConversations
+	TimelineBookmarks
+	TimelineFavourites
+)
 
 type Timeline struct {
-	Items        []TimelineItem
-	Selected     TimelineItem
-	Account      *mastodon.Account
+	Kind        TimelineKind
+	Items       []TimelineItem
+	Selected    TimelineItem
+	Account     *mastodon.Account
+	AccountTab  AccountTab
+	AccountList AccountList
+	List        *api.List
+	// Account lists, conversations and saved statuses are paginated by the
+	// Link header rather than by the item IDs
+	NextMaxID mastodon.ID
+	// ShowHidden shows the statuses hidden by the local mute rules
+	ShowHidden   bool
 	scrollOffset int
+	// Every item loaded, Items being those shown, and the IDs of the items
+	// the mute rules hide
+	all   []TimelineItem
+	muted map[mastodon.ID]bool
+	// The newest and oldest statuses the server returned, before filters
+	// dropped any, which page the timeline even when a whole page is hidden
+	newestID mastodon.ID
+	oldestID mastodon.ID
+	// Stops the stream that feeds the timeline, if any
+	cancel context.CancelFunc
 }
 
-func (v *TimelineView) AddTimeline(items []TimelineItem, selected TimelineItem, account *mastodon.Account) {
+func (v *TimelineView) AddTimeline(kind TimelineKind, items []TimelineItem, selected TimelineItem, account *mastodon.Account) {
 	if len(items) == 0 {
 		if account == nil {
 			return
@@ -32,6 +127,7 @@ func (v *TimelineView) AddTimeline(items []TimelineItem, selected TimelineItem,
 	}
 
 	t := Timeline{
+		Kind:         kind,
 		Items:        items,
 		Selected:     selectedItem,
 		scrollOffset: 0,
@@ -41,8 +137,199 @@ func (v *TimelineView) AddTimeline(items []TimelineItem, selected TimelineItem,
 		t.Account = account
 	}
 
-	if selectedItem != nil {
-		v.readStatuses[selectedItem.ID()] = true
+	v.PushTimeline(t)
+}
+
+// Returns the filter context of the timeline, empty for timelines that do not
+// show statuses. Saved statuses are shown whatever filters match them
+func (t *Timeline) filterContext() string {
+	switch t.Kind {
+	case TimelineHome, TimelineList:
+		return api.FilterContextHome
+	case TimelineAccount:
+		return api.FilterContextAccount
+	case TimelineThread:
+		return api.FilterContextThread
+	default:
+		return ""
+	}
+}
+
+// Drops the statuses a filter hides in the context of the timeline
+func (v *TimelineView) dropFiltered(t *Timeline, items []TimelineItem) []TimelineItem {
+	context := t.filterContext()
+	if context == "" {
+		return items
+	}
+	kept := items[:0:0]
+	for _, item := range items {
+		if status, ok := item.(StatusItem); ok && filterAction(status.Status, context) == api.FilterActionHide {
+			continue
+		}
+		kept = append(kept, item)
+	}
+	return kept
+}
+
+// Moves the paging cursors of the timeline to the ends of a page as the
+// server returned it. A page loaded before the items keeps the newest end,
+// one loaded after them the oldest
+func (t *Timeline) setCursors(page []TimelineItem, newer, older bool) {
+	if len(page) == 0 {
+		return
+	}
+	if first, ok := page[0].(StatusItem); ok && newer {
+		t.newestID = first.Status.ID
+	}
+	if last, ok := page[len(page)-1].(StatusItem); ok && older {
+		t.oldestID = last.Status.ID
+	}
+}
+
+// Reports whether the local mute rules apply to the timeline
+func (t *Timeline) mutes() bool {
+	switch t.filterContext() {
+	case api.FilterContextHome, api.FilterContextAccount:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stores every item of the timeline and shows those the mute rules do not
+// hide. A selected item that gets hidden passes the selection to the nearest
+// item shown before it
+func (v *TimelineView) setAll(index int, t *Timeline, all []TimelineItem) {
+	if t.muted == nil {
+		t.muted = make(map[mastodon.ID]bool)
+	}
+	if t.mutes() {
+		for _, item := range all {
+			if _, ok := t.muted[item.ID()]; !ok {
+				t.muted[item.ID()] = v.app.Muted(item)
+			}
+		}
+	}
+
+	t.all = all
+	t.Items = all
+	if !t.ShowHidden && t.HiddenCount() > 0 {
+		t.Items = make([]TimelineItem, 0, len(all))
+		for _, item := range all {
+			if !t.muted[item.ID()] {
+				t.Items = append(t.Items, item)
+			}
+		}
+	}
+
+	if index == v.index && index < len(v.timelines) {
+		v.setTitle()
+	}
+
+	if t.Selected == nil {
+		if len(t.Items) > 0 {
+			t.Selected = t.Items[0]
+		}
+		return
+	}
+	selectedID := t.Selected.ID()
+	var before TimelineItem
+	found := false
+	for _, item := range all {
+		shown := t.ShowHidden || !t.muted[item.ID()]
+		switch {
+		case item.ID() == selectedID && shown:
+			t.Selected = item
+			return
+		case item.ID() == selectedID:
+			found = true
+			if before != nil {
+				t.Selected = before
+				return
+			}
+		case shown && found:
+			t.Selected = item
+			return
+		case shown:
+			before = item
+		}
+	}
+	t.Selected = before
+}
+
+// Returns how many loaded items the mute rules hide
+func (t *Timeline) HiddenCount() int {
+	count := 0
+	for _, item := range t.all {
+		if t.muted[item.ID()] {
+			count++
+		}
+	}
+	return count
+}
+
+// Shows or hides the statuses hidden by the mute rules in the current timeline
+func (v *TimelineView) ToggleHidden() {
+	t := v.Current()
+	if t == nil {
+		return
+	}
+	t.ShowHidden = !t.ShowHidden
+	v.setAll(v.index, t, t.all)
+	t.scrollOffset = max(min(t.scrollOffset, len(t.Items)-1), 0)
+	if t.Selected != nil {
+		selectedID := t.Selected.ID()
+		for i, item := range t.Items {
+			if item.ID() == selectedID {
+				v.scrollTo(i)
+				break
+			}
+		}
+	}
+	v.setTitle()
+}
+
+// Applies the mute rules again to every timeline, after the accounts we
+// follow changed or loaded, since replies are hidden by whom they reply to
+func (v *TimelineView) RefreshMuted() {
+	for i := range v.timelines {
+		t := &v.timelines[i]
+		t.muted = nil
+		v.setAll(i, t, t.all)
+		t.scrollOffset = max(min(t.scrollOffset, len(t.Items)-1), 0)
+	}
+	if t := v.Current(); t != nil && t.Selected != nil {
+		selectedID := t.Selected.ID()
+		for i, item := range t.Items {
+			if item.ID() == selectedID {
+				v.scrollTo(i)
+				break
+			}
+		}
+	}
+}
+
+// Loads the relationships with the accounts of items that arrived, for their
+// note indicators and the mute rules. Those already requested are skipped
+func (v *TimelineView) requestRelationships(items []TimelineItem) {
+	ids := make([]mastodon.ID, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, itemAccountID(item))
+	}
+	v.app.RequestRelationships(ids)
+}
+
+// Pushes a timeline, even one without items, and shows it
+func (v *TimelineView) PushTimeline(t Timeline) {
+	v.requestRelationships(t.Items)
+	t.setCursors(t.Items, true, true)
+	items := v.dropFiltered(&t, t.Items)
+	if t.Selected != nil && !slices.ContainsFunc(items, func(item TimelineItem) bool { return item.ID() == t.Selected.ID() }) {
+		t.Selected = nil
+	}
+	v.setAll(len(v.timelines), &t, items)
+	if t.Selected != nil {
+		v.readStatuses[t.Selected.ID()] = true
 	}
 
 	v.timelines = append(v.timelines, t)
@@ -55,6 +342,9 @@ func (v *TimelineView) RemoveLastTimeline() {
 		return
 	}
 
+	if cancel := v.timelines[len(v.timelines)-1].cancel; cancel != nil {
+		cancel()
+	}
 	v.timelines = v.timelines[:len(v.timelines)-1]
 
 	if v.index >= len(v.timelines) {
@@ -70,8 +360,8 @@ func (v *TimelineView) UpdateTimeline(index int, newItems []TimelineItem, prepen
 	}
 
 	timeline := &v.timelines[index]
-	items := timeline.Items
-	selected := timeline.Selected
+	items := timeline.all
+	timeline.setCursors(newItems, prepend, !prepend)
 
 	// Create a set of existing IDs for deduplication
 	existingIDs := make(map[mastodon.ID]struct{})
@@ -80,7 +370,7 @@ func (v *TimelineView) UpdateTimeline(index int, newItems []TimelineItem, prepen
 	}
 
 	var freshItems []TimelineItem
-	for _, item := range newItems {
+	for _, item := range v.dropFiltered(timeline, newItems) {
 		if _, exists := existingIDs[item.ID()]; !exists {
 			freshItems = append(freshItems, item)
 		}
@@ -89,6 +379,7 @@ func (v *TimelineView) UpdateTimeline(index int, newItems []TimelineItem, prepen
 	if len(freshItems) == 0 {
 		return
 	}
+	v.requestRelationships(freshItems)
 
 	if prepend {
 		items = append(freshItems, items...)
@@ -96,17 +387,24 @@ func (v *TimelineView) UpdateTimeline(index int, newItems []TimelineItem, prepen
 		items = append(items, freshItems...)
 	}
 
-	v.timelines[index].Items = items
+	v.setAll(index, timeline, items)
+}
+
+// Replaces the items of a timeline, keeping the selected item if it is still there
+func (v *TimelineView) SetItems(index int, items []TimelineItem) {
+	if index < 0 || index >= len(v.timelines) {
+		return
+	}
 
-	if selected != nil {
-		targetID := selected.ID()
-		for _, item := range items {
-			if item.ID() == targetID {
-				v.timelines[index].Selected = item
-				break
-			}
-		}
+	timeline := &v.timelines[index]
+	timeline.setCursors(items, true, true)
+	items = v.dropFiltered(timeline, items)
+	v.requestRelationships(items)
+	if timeline.Selected != nil && !slices.ContainsFunc(items, func(item TimelineItem) bool { return item.ID() == timeline.Selected.ID() }) {
+		timeline.Selected = nil
 	}
+	v.setAll(index, timeline, items)
+	timeline.scrollOffset = 0
 }
 
 func (v *TimelineView) PrependToTimeline(index int, newItems []TimelineItem) {
@@ -123,20 +421,16 @@ func (v *TimelineView) UpdateEdit(index int, newItem TimelineItem) {
 	}
 
 	timeline := &v.timelines[index]
-	items := timeline.Items
-	selected := timeline.Selected
-
-	var selectedID mastodon.ID
-	if selected != nil {
-		selectedID = selected.ID()
+	if len(v.dropFiltered(timeline, []TimelineItem{newItem})) == 0 {
+		v.DeleteFromTimeline(index, newItem.ID())
+		return
 	}
-
-	for i, item := range items {
+	for i, item := range timeline.all {
 		if item.ID() == newItem.ID() {
-			v.timelines[index].Items[i] = newItem
-			if selected != nil && selectedID == newItem.ID() {
-				v.timelines[index].Selected = newItem
-			}
+			timeline.all[i] = newItem
+			// An edit can change whether the mute rules match
+			delete(timeline.muted, newItem.ID())
+			v.setAll(index, timeline, timeline.all)
 			break
 		}
 	}
@@ -151,33 +445,83 @@ func (v *TimelineView) DeleteFromTimeline(index int, targetID mastodon.ID) {
 	items := timeline.Items
 	selected := timeline.Selected
 
-	if len(items) == 0 {
-		return
+	if selected != nil && selected.ID() == targetID {
+		deleteIndex := slices.IndexFunc(items, func(item TimelineItem) bool { return item.ID() == targetID })
+		if len(items) == 1 {
+			timeline.Selected = nil
+		} else if deleteIndex == 0 {
+			timeline.Selected = items[1]
+		} else if deleteIndex > 0 {
+			timeline.Selected = items[deleteIndex-1]
+		}
 	}
 
-	var deleteIndex int = -1
-	for i, item := range items {
-		if item.ID() == targetID {
-			deleteIndex = i
-			break
+	// Items is rebuilt from all, which it may share an array with
+	all := slices.DeleteFunc(timeline.all, func(item TimelineItem) bool { return item.ID() == targetID })
+	v.setAll(index, timeline, all)
+}
+
+// Returns the index of the open timeline of a list, or -1 when it is closed
+func (v *TimelineView) listIndex(id mastodon.ID) int {
+	for i := len(v.timelines) - 1; i >= 0; i-- {
+		if t := v.timelines[i]; t.Kind == TimelineList && t.List.ID == id {
+			return i
 		}
 	}
+	return -1
+}
 
-	if deleteIndex == -1 {
-		return
+// Returns the index of the open timeline of our conversations, or -1 when it
+// is closed
+func (v *TimelineView) conversationsIndex() int {
+	for i := len(v.timelines) - 1; i >= 0; i-- {
+		if v.timelines[i].Kind == TimelineConversations {
+			return i
+		}
 	}
+	return -1
+}
 
-	if selected != nil && selected.ID() == targetID {
-		if len(items) == 1 {
-			v.timelines[index].Selected = nil
-		} else if deleteIndex == 0 {
-			v.timelines[index].Selected = items[1]
-		} else {
-			v.timelines[index].Selected = items[deleteIndex-1]
+// Replaces a status in every timeline, boosts of it included
+func (v *TimelineView) UpdateStatus(status *mastodon.Status) {
+	for i := range v.timelines {
+		for _, item := range slices.Clone(v.timelines[i].all) {
+			s, ok := item.(StatusItem)
+			if !ok {
+				continue
+			}
+			if s.Status.ID == status.ID {
+				v.UpdateEdit(i, StatusItem{Status: status})
+			} else if s.Reblog != nil && s.Reblog.ID == status.ID {
+				boost := *s.Status
+				boost.Reblog = status
+				v.UpdateEdit(i, StatusItem{Status: &boost})
+			}
 		}
 	}
+}
 
-	v.timelines[index].Items = append(items[:deleteIndex], items[deleteIndex+1:]...)
+// Removes a deleted status from every timeline, boosts of it included
+func (v *TimelineView) DeleteStatus(id mastodon.ID) {
+	for i := range v.timelines {
+		for _, item := range slices.Clone(v.timelines[i].all) {
+			s, ok := item.(StatusItem)
+			if !ok {
+				continue
+			}
+			if s.Status.ID == id || (s.Reblog != nil && s.Reblog.ID == id) {
+				v.DeleteFromTimeline(i, s.Status.ID)
+			}
+		}
+	}
+}
+
+// Returns the timeline shown, or nil before the first one loads
+func (v *TimelineView) Current() *Timeline {
+	if v.index >= len(v.timelines) {
+		return nil
+	}
+	return &v.timelines[v.index]
 }
 
 func (v *TimelineView) SelectedItem() TimelineItem {
@@ -1,6 +1,9 @@
 package tui
 
-import "github.com/mattn/go-mastodon"
+import (
+	"github.com/AbeEstrada/tuit/api"
+	"github.com/mattn/go-mastodon"
+)
 
 type TimelineItem interface {
 	ID() mastodon.ID
@@ -21,3 +24,27 @@ type AccountItem struct {
 func (a AccountItem) ID() mastodon.ID {
 	return a.Account.ID
 }
+
+type ListItem struct {
+	*api.List
+}
+
+func (l ListItem) ID() mastodon.ID {
+	return l.List.ID
+}
+
+type FilterItem struct {
+	*api.Filter
+}
+
+func (f FilterItem) ID() mastodon.ID {
+	return f.Filter.ID
+}
+
+type ConversationItem struct {
+	*mastodon.Conversation
+}
+
+func (c ConversationItem) ID() mastodon.ID {
+	return c.Conversation.ID
+}
@@ -5,8 +5,11 @@ import (
 	_ "image/jpeg"
 	_ "image/png"
 	"log"
+	"strings"
+	"time"
 
 	"git.sr.ht/~rockorager/vaxis"
+	"github.com/AbeEstrada/tuit/config"
 	"github.com/AbeEstrada/tuit/utils"
 	"github.com/mattn/go-mastodon"
 )
@@ -17,6 +20,10 @@ type TimelineView struct {
 	index        int
 	onLoadMore   func()
 	readStatuses map[mastodon.ID]bool
+	// Statuses collapsed by a filter that were shown anyway
+	filterRevealed map[mastodon.ID]bool
+	viewWidth      int
+	viewHeight     int
 }
 
 func CreateTimelineView() *TimelineView {
@@ -24,6 +31,8 @@ func CreateTimelineView() *TimelineView {
 		timelines:    []Timeline{},
 		index:        0,
 		readStatuses: make(map[mastodon.ID]bool),
+
+		filterRevealed: make(map[mastodon.ID]bool),
 	}
 }
 
@@ -36,20 +45,312 @@ func (v *TimelineView) setTitle() {
 		return
 	}
 	timeline := &v.timelines[v.index]
-	v.app.header.SetBadgeVisible(v.index == 0)
-	switch {
-	case v.index == 0:
-		v.app.header.SetText("Home")
-	case timeline.Account != nil:
-		v.app.header.SetText("Home → " + timeline.Account.DisplayName)
-	default: // v.index != 0 && timeline.Account == nil
-		v.app.header.SetText("Home → Thread")
+	v.app.header.SetBadgeVisible(timeline.Kind == TimelineHome)
+	var title string
+	switch timeline.Kind {
+	case TimelineHome:
+		title = "Home"
+	case TimelineLists:
+		title = "Home → Lists"
+	case TimelineList:
+		title = "Home → Lists → " + timeline.List.Title
+	case TimelineFilters:
+		title = "Home → Filters"
+	case TimelineConversations:
+		title = "Home → Conversations"
+	case TimelineBookmarks:
+		title = "Home → Bookmarks"
+	case TimelineFavourites:
+		title = "Home → Favorites"
+	case TimelineAccountList:
+		title = "Home → " + timeline.Account.DisplayName + " → " + timeline.AccountList.String()
+	case TimelineAccount:
+		title = "Home → " + timeline.Account.DisplayName
+	default:
+		title = "Home → Thread"
+	}
+
+	if hidden := timeline.HiddenCount(); hidden > 0 && timeline.ShowHidden {
+		title += fmt.Sprintf(" · showing %d hidden", hidden)
+	} else if hidden > 0 {
+		title += fmt.Sprintf(" · %d hidden", hidden)
+	}
+	v.app.header.SetText(title)
+}
+
+func (v *TimelineView) multiLine() bool {
+	return v.app.config.Timeline.RowLayout == config.RowLayoutMultiLine
+}
+
+// Returns the lines used to render an item, each line being a slice of segments
+func (v *TimelineView) itemLines(item TimelineItem, width int) [][]vaxis.Segment {
+	bold := vaxis.Style{Attribute: vaxis.AttrBold}
+
+	switch t := item.(type) {
+	case StatusItem:
+		status := t.Status
+		displayStatus := status
+		if status.Reblog != nil {
+			displayStatus = status.Reblog
+		}
+
+		createdAt := status.CreatedAt.Local()
+		timestamp := createdAt.Format("2006-01-02 15:04")
+		if width < 60 {
+			timestamp = createdAt.Format("15:04")
+		}
+
+		statusType := " "
+		if status.Reblog != nil {
+			statusType = "♺"
+		} else if status.InReplyToID != nil {
+			statusType = "↩"
+		}
+
+		header := []vaxis.Segment{
+			{Text: fmt.Sprintf("%s %s ", timestamp, statusType)},
+		}
+		if displayStatus.Account.DisplayName != "" {
+			header = append(header, vaxis.Segment{Text: displayStatus.Account.DisplayName + " ", Style: bold})
+		}
+		header = append(header, vaxis.Segment{Text: "@" + displayStatus.Account.Acct})
+		if v.hasNote(displayStatus.Account.ID) {
+			header = append(header, vaxis.Segment{Text: " " + noteIndicator, Style: vaxis.Style{Attribute: vaxis.AttrDim}})
+		}
+
+		var preview []vaxis.Segment
+		if title := v.filterWarning(status); title != "" {
+			// Nothing of a filtered status is shown until it is revealed
+			line := []vaxis.Segment{
+				{Text: fmt.Sprintf("%s %s ", timestamp, statusType)},
+				{Text: "Filtered: " + title, Style: vaxis.Style{Attribute: vaxis.AttrDim | vaxis.AttrItalic}},
+			}
+			return [][]vaxis.Segment{line}
+		} else if displayStatus.SpoilerText != "" {
+			preview = append(preview, vaxis.Segment{
+				Text:  "CW: " + displayStatus.SpoilerText,
+				Style: vaxis.Style{Foreground: vaxis.IndexColor(3)},
+			})
+		} else if text := utils.FirstLine(utils.ParseStatus(displayStatus.Content, displayStatus.Tags)); text != "" {
+			preview = append(preview, vaxis.Segment{Text: text})
+		}
+
+		indicators := statusIndicators(displayStatus)
+
+		if !v.multiLine() {
+			line := header
+			if indicators != "" {
+				line = append(line, vaxis.Segment{Text: " " + indicators})
+			}
+			if len(preview) > 0 {
+				line = append(line, vaxis.Segment{Text: " · "})
+				line = append(line, preview...)
+			}
+			return [][]vaxis.Segment{line}
+		}
+
+		if status.Reblog != nil {
+			header = append(header, vaxis.Segment{Text: " ♺ @" + status.Account.Acct})
+		}
+		lines := [][]vaxis.Segment{header}
+		if len(preview) > 0 {
+			lines = append(lines, preview)
+		}
+		if indicators != "" {
+			lines = append(lines, []vaxis.Segment{{Text: indicators}})
+		}
+		return lines
+
+	case AccountItem:
+		var line []vaxis.Segment
+		if t.DisplayName != "" {
+			line = append(line, vaxis.Segment{Text: t.DisplayName + " ", Style: bold})
+		}
+		line = append(line, vaxis.Segment{Text: "@" + t.Acct})
+		if v.hasNote(t.Account.ID) {
+			line = append(line, vaxis.Segment{Text: " " + noteIndicator, Style: vaxis.Style{Attribute: vaxis.AttrDim}})
+		}
+		if label, color := relationshipLabel(v.app.Relationship(t.Account.ID)); label != "" {
+			line = append(line, vaxis.Segment{Text: " " + label, Style: vaxis.Style{Foreground: color}})
+		}
+		return [][]vaxis.Segment{line}
+
+	case ListItem:
+		line := []vaxis.Segment{{Text: "☰ " + t.Title, Style: bold}}
+		if t.Exclusive {
+			line = append(line, vaxis.Segment{Text: " · exclusive", Style: vaxis.Style{Attribute: vaxis.AttrDim}})
+		}
+		return [][]vaxis.Segment{line}
+
+	case ConversationItem:
+		return v.conversationLines(t.Conversation)
+
+	case FilterItem:
+		dim := vaxis.Style{Attribute: vaxis.AttrDim}
+		line := []vaxis.Segment{
+			{Text: t.Title, Style: bold},
+			{Text: fmt.Sprintf(" · %d keywords · %s", len(t.Keywords), t.FilterAction), Style: dim},
+		}
+		if t.Expired(time.Now()) {
+			line = append(line, vaxis.Segment{Text: " · expired", Style: dim})
+		}
+		return [][]vaxis.Segment{line}
+	}
+
+	return nil
+}
+
+// Returns the participants of a conversation and a preview of its last status
+func (v *TimelineView) conversationLines(conversation *mastodon.Conversation) [][]vaxis.Segment {
+	var names []string
+	for _, account := range conversation.Accounts {
+		if account.DisplayName != "" {
+			names = append(names, account.DisplayName)
+		} else {
+			names = append(names, "@"+account.Acct)
+		}
+	}
+	if len(names) == 0 {
+		names = append(names, "Only you")
+	}
+
+	marker := vaxis.Segment{Text: "  "}
+	var nameStyle vaxis.Style
+	if conversation.Unread {
+		marker = vaxis.Segment{Text: "● ", Style: vaxis.Style{Foreground: vaxis.IndexColor(4)}}
+		nameStyle.Attribute = vaxis.AttrBold
+	}
+
+	var timestamp string
+	var preview []vaxis.Segment
+	if status := conversation.LastStatus; status != nil {
+		timestamp = status.CreatedAt.Local().Format("2006-01-02 15:04") + " "
+		if status.SpoilerText != "" {
+			preview = append(preview, vaxis.Segment{
+				Text:  "CW: " + status.SpoilerText,
+				Style: vaxis.Style{Foreground: vaxis.IndexColor(3)},
+			})
+		} else if text := utils.FirstLine(utils.ParseStatus(status.Content, status.Tags)); text != "" {
+			preview = append(preview, vaxis.Segment{Text: text})
+		}
+	}
+
+	header := []vaxis.Segment{
+		{Text: timestamp},
+		marker,
+		{Text: strings.Join(names, ", "), Style: nameStyle},
+	}
+	if !v.multiLine() {
+		if len(preview) > 0 {
+			header = append(header, vaxis.Segment{Text: " · "})
+			header = append(header, preview...)
+		}
+		return [][]vaxis.Segment{header}
+	}
+	lines := [][]vaxis.Segment{header}
+	if len(preview) > 0 {
+		lines = append(lines, preview)
 	}
+	return lines
+}
+
+func (v *TimelineView) hasNote(id mastodon.ID) bool {
+	relationship := v.app.Relationship(id)
+	return relationship != nil && relationship.Note != ""
+}
+
+// Returns the account an item is about, the author for statuses
+func itemAccountID(item TimelineItem) mastodon.ID {
+	switch t := item.(type) {
+	case StatusItem:
+		if t.Reblog != nil {
+			return t.Reblog.Account.ID
+		}
+		return t.Account.ID
+	case AccountItem:
+		return t.Account.ID
+	case ConversationItem:
+		if len(t.Accounts) > 0 {
+			return t.Accounts[0].ID
+		}
+	}
+	return ""
+}
+
+func (v *TimelineView) itemEmojis(item TimelineItem) map[string]string {
+	switch t := item.(type) {
+	case StatusItem:
+		if t.Reblog != nil {
+			return v.app.Emojis(t.Reblog.Emojis, t.Reblog.Account.Emojis, t.Account.Emojis)
+		}
+		return v.app.Emojis(t.Emojis, t.Account.Emojis)
+	case AccountItem:
+		return v.app.Emojis(t.Emojis)
+	}
+	return nil
+}
+
+// Returns the number of rows an item takes, including the spacer between
+// items in the multi-line layout
+func (v *TimelineView) itemHeight(item TimelineItem, width int) int {
+	height := len(v.itemLines(item, width))
+	if v.multiLine() && height > 0 {
+		height++
+	}
+	return height
+}
+
+// Builds a compact summary of the media, poll and link attached to a status
+func statusIndicators(status *mastodon.Status) string {
+	var indicators []string
+	if n := len(status.MediaAttachments); n > 0 {
+		glyph := "▣"
+		for _, media := range status.MediaAttachments {
+			if media.Type == "video" || media.Type == "gifv" {
+				glyph = "▶"
+				break
+			}
+		}
+		indicators = append(indicators, fmt.Sprintf("%s%d", glyph, n))
+	}
+	if status.Poll != nil {
+		indicators = append(indicators, "☰")
+	}
+	if status.Card != nil && status.Card.URL != "" {
+		indicators = append(indicators, "↗")
+	} else if url := utils.ExtractFirstExternalURL(status.Content); url != "" && !utils.IsTagLink(url) {
+		indicators = append(indicators, "↗")
+	}
+	return strings.Join(indicators, " ")
 }
 
 func (v *TimelineView) Draw(win vaxis.Window, focused bool) {
 	width, height := win.Size()
+	v.viewWidth = width
+	v.viewHeight = height - 3
 
+	if timeline := v.Current(); timeline != nil && len(timeline.Items) == 0 {
+		empty := ""
+		switch timeline.Kind {
+		case TimelineLists:
+			empty = "No lists yet, press n to create one"
+		case TimelineFilters:
+			empty = "No filters yet, press n to create one"
+		case TimelineConversations:
+			empty = "No conversations yet, press c to write to someone"
+		case TimelineBookmarks:
+			empty = "No bookmarks yet, press b on a status to add one"
+		case TimelineFavourites:
+			empty = "No favorites yet, press f on a status to add one"
+		}
+		if empty == "" && timeline.HiddenCount() > 0 {
+			empty = "Every status loaded is muted, press H to show them"
+		}
+		if empty != "" {
+			win.Println(0, vaxis.Segment{Text: empty})
+			return
+		}
+	}
 	if v.index >= len(v.timelines) || len(v.timelines[v.index].Items) == 0 {
 		win.Println(0, vaxis.Segment{Text: "Loading..."})
 		return
@@ -62,33 +363,14 @@ func (v *TimelineView) Draw(win vaxis.Window, focused bool) {
 	scrollOffset := timeline.scrollOffset
 
 	y := 0
-	for i := scrollOffset; i < len(items) && y < height-2; i++ {
+	for i := scrollOffset; i < len(items) && y < v.viewHeight; i++ {
 		item := items[i]
 
-		var displayText string
-
-		switch t := item.(type) {
-		case StatusItem:
-			createdAt := t.CreatedAt.Local()
-			timestamp := createdAt.Format("2006-01-02 15:04")
-			if width < 60 {
-				timestamp = createdAt.Format("15:04")
-			}
-
-			statusType := " "
-			if t.Reblog != nil {
-				statusType = "♺"
-			} else if t.InReplyToID != nil {
-				statusType = "↩"
-			}
-			displayText = fmt.Sprintf("%s %s @%s", timestamp, statusType, t.Account.Acct)
-
-		case AccountItem:
-			displayText = fmt.Sprintf("@%s", t.Acct)
-
-		default:
+		lines := v.itemLines(item, width)
+		if len(lines) == 0 {
 			continue
 		}
+		emojis := v.itemEmojis(item)
 
 		var attr vaxis.AttributeMask
 		isSelected := item.ID() == selectedID && focused
@@ -99,11 +381,56 @@ func (v *TimelineView) Draw(win vaxis.Window, focused bool) {
 			attr |= vaxis.AttrReverse
 		}
 
-		win.Println(y, vaxis.Segment{
-			Text:  displayText,
-			Style: vaxis.Style{Attribute: attr},
-		})
-		y++
+		for _, line := range lines {
+			if y >= v.viewHeight {
+				break
+			}
+			segments := make([]vaxis.Segment, len(line))
+			for j, seg := range line {
+				seg.Style.Attribute |= attr
+				segments[j] = seg
+			}
+			if isSelected {
+				// Fill the row so the selection covers the whole width
+				win.New(0, y, width, 1).Fill(vaxis.Cell{
+					Character: vaxis.Character{Grapheme: " ", Width: 1},
+					Style:     vaxis.Style{Attribute: attr},
+				})
+			}
+			printText(win, y, emojis, segments...)
+			y++
+		}
+
+		if v.multiLine() {
+			y++
+		}
+	}
+}
+
+// Adjusts the scroll offset of the current timeline so the item at index is
+// fully visible
+func (v *TimelineView) scrollTo(index int) {
+	timeline := &v.timelines[v.index]
+	items := timeline.Items
+
+	if index < timeline.scrollOffset {
+		timeline.scrollOffset = index
+		return
+	}
+
+	viewHeight := v.viewHeight
+	if viewHeight <= 0 {
+		_, height := v.app.vx.Window().Size()
+		viewHeight = height - 3
+	}
+
+	used := 0
+	for i := timeline.scrollOffset; i <= index; i++ {
+		used += v.itemHeight(items[i], v.viewWidth)
+	}
+	for used > viewHeight && timeline.scrollOffset < index {
+		used -= v.itemHeight(items[timeline.scrollOffset], v.viewWidth)
+		timeline.scrollOffset++
 	}
 }
 
@@ -116,7 +443,6 @@ func (v *TimelineView) HandleKey(key vaxis.Key) {
 	items := timeline.Items
 	selected := timeline.Selected
 	selectedID := selected.ID()
-	scrollOffset := timeline.scrollOffset
 
 	currentIndex := -1
 	if selected != nil {
@@ -155,9 +481,7 @@ func (v *TimelineView) HandleKey(key vaxis.Key) {
 				url = status.URL
 			}
 			if url != "" {
-				if err := utils.OpenBrowser(url); err != nil {
-					log.Printf("Failed to open URL: %v", err)
-				}
+				v.app.OpenURL(url, "")
 			}
 		}
 	case key.Matches('o'):
@@ -170,15 +494,11 @@ func (v *TimelineView) HandleKey(key vaxis.Key) {
 				url = fmt.Sprintf("%s/@%s/%s", v.app.config.Auth.Server, status.Account.Acct, statusID)
 			}
 			if url != "" {
-				if err := utils.OpenBrowser(url); err != nil {
-					log.Printf("Failed to open URL: %v", err)
-				}
+				v.app.OpenURL(url, "")
 			}
 		} else if account, ok := selected.(AccountItem); ok {
 			if account.URL != "" {
-				if err := utils.OpenBrowser(account.URL); err != nil {
-					log.Printf("Failed to open URL: %v", err)
-				}
+				v.app.OpenURL(account.URL, "")
 			}
 		}
 		return
@@ -199,9 +519,7 @@ func (v *TimelineView) HandleKey(key vaxis.Key) {
 				}
 			}
 			if url != "" {
-				if err := utils.OpenBrowser(url); err != nil {
-					log.Printf("Failed to open URL: %v", err)
-				}
+				v.app.OpenURL(url, "")
 			} else {
 				log.Printf("No URL available to open")
 			}
@@ -221,17 +539,12 @@ func (v *TimelineView) HandleKey(key vaxis.Key) {
 		return
 	}
 
-	_, height := v.app.vx.Window().Size()
-	if newIndex >= scrollOffset+height-4 {
-		v.timelines[v.index].scrollOffset = newIndex - (height - 5)
-	}
-	if newIndex < scrollOffset {
-		v.timelines[v.index].scrollOffset = newIndex
-	}
+	v.scrollTo(newIndex)
 
 	newSelected := items[newIndex]
 	if selected == nil || newSelected.ID() != selected.ID() {
 		v.timelines[v.index].Selected = newSelected
+		v.requestRelationships([]TimelineItem{newSelected})
 	}
 	v.readStatuses[newSelected.ID()] = true
 }
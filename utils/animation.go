@@ -0,0 +1,571 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"time"
+
+	"golang.org/x/image/webp"
+)
+
+const (
+	// MaxAnimationPixels caps the pixels of all the decoded frames of an
+	// animation together. Frames past the cap are dropped
+	MaxAnimationPixels = 16 << 20
+	// MaxAnimationFrames caps the frames of an animation, which matters for
+	// tiny ones where the pixel cap allows millions
+	MaxAnimationFrames = 1000
+
+	defaultFrameDelay = 100 * time.Millisecond
+	minFrameDelay     = 20 * time.Millisecond
+)
+
+// Animation is a sequence of fully composited frames of the same size. It
+// implements image.Image as its first frame, so it can be cached and drawn
+// like any other image
+type Animation struct {
+	image.Image
+	Frames []image.Image
+	Delays []time.Duration
+}
+
+// Reports whether the animation has more than one frame
+func (a *Animation) Animated() bool {
+	return len(a.Frames) > 1
+}
+
+// Reports whether another frame of the given pixels would pass the caps. The
+// first frame always fits
+func (a *Animation) full(pixels int64) bool {
+	frames := len(a.Frames)
+	return frames > 0 && (frames >= MaxAnimationFrames || int64(frames+1)*pixels > MaxAnimationPixels)
+}
+
+func (a *Animation) append(frame image.Image, delay time.Duration) bool {
+	bounds := frame.Bounds()
+	if a.full(int64(bounds.Dx()) * int64(bounds.Dy())) {
+		return false
+	}
+	if delay < minFrameDelay {
+		delay = defaultFrameDelay
+	}
+	a.Frames = append(a.Frames, frame)
+	a.Delays = append(a.Delays, delay)
+	if a.Image == nil {
+		a.Image = frame
+	}
+	return true
+}
+
+// Decodes an animated GIF, APNG or WebP. Still images decode to a single frame
+func DecodeAnimation(data []byte) (*Animation, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		return decodeGIFAnimation(data)
+	case bytes.HasPrefix(data, []byte(pngSignature)):
+		return decodeAPNG(data)
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return decodeWebPAnimation(data)
+	}
+
+	img, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+	anim := &Animation{}
+	anim.append(img, 0)
+	return anim, nil
+}
+
+func checkCanvas(width, height int) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid animation size %dx%d", width, height)
+	}
+	if int64(width)*int64(height) > MaxImagePixels {
+		return fmt.Errorf("image too large: %dx%d pixels", width, height)
+	}
+	return nil
+}
+
+func cloneRGBA(src *image.RGBA) *image.RGBA {
+	dst := image.NewRGBA(src.Rect)
+	copy(dst.Pix, src.Pix)
+	return dst
+}
+
+// Returns the end of the data sub-blocks starting at pos, past their
+// terminating empty block
+func skipGIFSubBlocks(data []byte, pos int) (int, error) {
+	for pos < len(data) {
+		size := int(data[pos])
+		pos++
+		if size == 0 {
+			return pos, nil
+		}
+		pos += size
+	}
+	return 0, errors.New("gif: truncated data")
+}
+
+// Decodes a GIF one frame at a time by rebuilding every frame as a standalone
+// GIF, reusing the header and global color table of the file. Decoding stops
+// before the frames pass the caps, so the rest are never decoded
+func decodeGIFAnimation(data []byte) (*Animation, error) {
+	cfg, err := gif.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCanvas(cfg.Width, cfg.Height); err != nil {
+		return nil, err
+	}
+	canvasPixels := int64(cfg.Width) * int64(cfg.Height)
+
+	// The header and logical screen descriptor, then the global color table
+	const screenEnd = 13
+	if len(data) < screenEnd {
+		return nil, errors.New("gif: truncated header")
+	}
+	headerEnd := screenEnd
+	if flags := data[10]; flags&0x80 != 0 {
+		headerEnd += 3 << (flags&0x07 + 1)
+	}
+
+	anim := &Animation{}
+	canvas := image.NewRGBA(image.Rect(0, 0, cfg.Width, cfg.Height))
+	var control []byte
+	pos := headerEnd
+	for pos < len(data) && data[pos] != 0x3b {
+		if data[pos] == 0x21 {
+			if pos+1 >= len(data) {
+				return nil, errors.New("gif: truncated extension")
+			}
+			end, err := skipGIFSubBlocks(data, pos+2)
+			if err != nil {
+				return nil, err
+			}
+			// The graphic control extension applies to the next frame
+			if data[pos+1] == 0xf9 && end-pos >= 8 {
+				control = data[pos:end]
+			}
+			pos = end
+			continue
+		}
+		if data[pos] != 0x2c {
+			return nil, fmt.Errorf("gif: unknown block 0x%02x", data[pos])
+		}
+
+		// The image descriptor, its local color table, then the image data
+		// after the minimum code size
+		dataStart := pos + 10
+		if dataStart > len(data) {
+			return nil, errors.New("gif: truncated image descriptor")
+		}
+		if flags := data[pos+9]; flags&0x80 != 0 {
+			dataStart += 3 << (flags&0x07 + 1)
+		}
+		end, err := skipGIFSubBlocks(data, dataStart+1)
+		if err != nil {
+			return nil, err
+		}
+		frameData := data[pos:end]
+		frameControl := control
+		pos = end
+		control = nil
+
+		// Every frame is composited onto a copy of the whole canvas
+		if anim.full(canvasPixels) {
+			break
+		}
+
+		var file bytes.Buffer
+		file.Write(data[:headerEnd])
+		file.Write(frameControl)
+		file.Write(frameData)
+		file.WriteByte(0x3b)
+		frame, err := gif.Decode(&file)
+		if err != nil {
+			return nil, err
+		}
+
+		var delay time.Duration
+		var disposal byte
+		if frameControl != nil {
+			disposal = frameControl[3] >> 2 & 0x07
+			delay = time.Duration(binary.LittleEndian.Uint16(frameControl[4:6])) * 10 * time.Millisecond
+		}
+
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = cloneRGBA(canvas)
+		}
+
+		bounds := frame.Bounds()
+		draw.Draw(canvas, bounds, frame, bounds.Min, draw.Over)
+		if !anim.append(cloneRGBA(canvas), delay) {
+			break
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, bounds, image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	if len(anim.Frames) == 0 {
+		return nil, errors.New("gif: no frames")
+	}
+	return anim, nil
+}
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// Reports whether the start of a PNG file has an acTL chunk, which an APNG
+// has before its image data
+func isAPNG(data []byte) bool {
+	if !bytes.HasPrefix(data, []byte(pngSignature)) {
+		return false
+	}
+	rest := data[len(pngSignature):]
+	for len(rest) >= 8 {
+		switch string(rest[4:8]) {
+		case "acTL":
+			return true
+		case "IDAT":
+			return false
+		}
+		length := binary.BigEndian.Uint32(rest[0:4])
+		if uint64(length)+12 > uint64(len(rest)) {
+			return false
+		}
+		rest = rest[12+length:]
+	}
+	return false
+}
+
+type pngChunk struct {
+	kind string
+	data []byte
+}
+
+func readPNGChunks(data []byte) ([]pngChunk, error) {
+	var chunks []pngChunk
+	rest := data[len(pngSignature):]
+	for len(rest) >= 12 {
+		length := binary.BigEndian.Uint32(rest[0:4])
+		if uint64(length)+12 > uint64(len(rest)) {
+			return nil, errors.New("png: truncated chunk")
+		}
+		chunks = append(chunks, pngChunk{
+			kind: string(rest[4:8]),
+			data: rest[8 : 8+length],
+		})
+		rest = rest[12+length:]
+	}
+	return chunks, nil
+}
+
+func writePNGChunk(buf *bytes.Buffer, kind string, data []byte) {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(data)))
+	copy(header[4:8], kind)
+	buf.Write(header[:])
+	buf.Write(data)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:8])
+	crc.Write(data)
+	binary.BigEndian.PutUint32(header[0:4], crc.Sum32())
+	buf.Write(header[0:4])
+}
+
+type apngFrame struct {
+	width, height int
+	x, y          int
+	delay         time.Duration
+	dispose       byte
+	blend         byte
+	data          [][]byte
+}
+
+// Decodes an APNG by rebuilding every frame as a standalone PNG, reusing the
+// header and ancillary chunks of the file. Plain PNGs decode to a single frame
+func decodeAPNG(data []byte) (*Animation, error) {
+	chunks, err := readPNGChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var ihdr []byte
+	var shared []pngChunk
+	var frames []*apngFrame
+	var current *apngFrame
+	animated := false
+	seenIDAT := false
+
+	for _, chunk := range chunks {
+		switch chunk.kind {
+		case "IHDR":
+			ihdr = chunk.data
+		case "acTL":
+			animated = true
+		case "fcTL":
+			if len(chunk.data) < 26 {
+				return nil, errors.New("apng: invalid fcTL chunk")
+			}
+			num := binary.BigEndian.Uint16(chunk.data[20:22])
+			den := binary.BigEndian.Uint16(chunk.data[22:24])
+			if den == 0 {
+				den = 100
+			}
+			current = &apngFrame{
+				width:   int(binary.BigEndian.Uint32(chunk.data[4:8])),
+				height:  int(binary.BigEndian.Uint32(chunk.data[8:12])),
+				x:       int(binary.BigEndian.Uint32(chunk.data[12:16])),
+				y:       int(binary.BigEndian.Uint32(chunk.data[16:20])),
+				delay:   time.Duration(num) * time.Second / time.Duration(den),
+				dispose: chunk.data[24],
+				blend:   chunk.data[25],
+			}
+			frames = append(frames, current)
+		case "IDAT":
+			seenIDAT = true
+			// The default image is only part of the animation when an
+			// fcTL chunk comes before it
+			if current != nil {
+				current.data = append(current.data, chunk.data)
+			}
+		case "fdAT":
+			if current != nil && len(chunk.data) > 4 {
+				current.data = append(current.data, chunk.data[4:])
+			}
+		case "IEND":
+		default:
+			if !seenIDAT {
+				shared = append(shared, chunk)
+			}
+		}
+	}
+
+	if !animated || len(frames) == 0 || len(ihdr) < 13 {
+		img, err := decodeImage(data)
+		if err != nil {
+			return nil, err
+		}
+		anim := &Animation{}
+		anim.append(img, 0)
+		return anim, nil
+	}
+
+	width := int(binary.BigEndian.Uint32(ihdr[0:4]))
+	height := int(binary.BigEndian.Uint32(ihdr[4:8]))
+	if err := checkCanvas(width, height); err != nil {
+		return nil, err
+	}
+
+	anim := &Animation{}
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	for _, frame := range frames {
+		if len(frame.data) == 0 {
+			continue
+		}
+		if err := checkCanvas(frame.width, frame.height); err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		buf.WriteString(pngSignature)
+		header := append([]byte(nil), ihdr...)
+		binary.BigEndian.PutUint32(header[0:4], uint32(frame.width))
+		binary.BigEndian.PutUint32(header[4:8], uint32(frame.height))
+		writePNGChunk(&buf, "IHDR", header)
+		for _, chunk := range shared {
+			writePNGChunk(&buf, chunk.kind, chunk.data)
+		}
+		for _, part := range frame.data {
+			writePNGChunk(&buf, "IDAT", part)
+		}
+		writePNGChunk(&buf, "IEND", nil)
+
+		img, err := png.Decode(&buf)
+		if err != nil {
+			return nil, err
+		}
+
+		rect := image.Rect(frame.x, frame.y, frame.x+frame.width, frame.y+frame.height)
+		var previous *image.RGBA
+		if frame.dispose == 2 {
+			previous = cloneRGBA(canvas)
+		}
+
+		op := draw.Over
+		if frame.blend == 0 {
+			op = draw.Src
+		}
+		draw.Draw(canvas, rect, img, img.Bounds().Min, op)
+		if !anim.append(cloneRGBA(canvas), frame.delay) {
+			break
+		}
+
+		switch frame.dispose {
+		case 1:
+			draw.Draw(canvas, rect, image.Transparent, image.Point{}, draw.Src)
+		case 2:
+			canvas = previous
+		}
+	}
+
+	if len(anim.Frames) == 0 {
+		return nil, errors.New("apng: no frames")
+	}
+	return anim, nil
+}
+
+func readUint24(b []byte) int {
+	return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+}
+
+func writeRIFFChunk(buf *bytes.Buffer, kind string, data []byte) {
+	var header [8]byte
+	copy(header[0:4], kind)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(len(data)))
+	buf.Write(header[:])
+	buf.Write(data)
+	if len(data)%2 == 1 {
+		buf.WriteByte(0)
+	}
+}
+
+func readRIFFChunks(data []byte) ([]pngChunk, error) {
+	var chunks []pngChunk
+	for len(data) >= 8 {
+		length := binary.LittleEndian.Uint32(data[4:8])
+		if uint64(length)+8 > uint64(len(data)) {
+			return nil, errors.New("webp: truncated chunk")
+		}
+		chunks = append(chunks, pngChunk{kind: string(data[0:4]), data: data[8 : 8+length]})
+		data = data[8+length:]
+		if length%2 == 1 && len(data) > 0 {
+			data = data[1:]
+		}
+	}
+	return chunks, nil
+}
+
+// Decodes an animated WebP by rebuilding every ANMF frame as a standalone
+// WebP file. Still WebPs decode to a single frame
+func decodeWebPAnimation(data []byte) (*Animation, error) {
+	chunks, err := readRIFFChunks(data[12:])
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := 0, 0
+	animated := false
+	for _, chunk := range chunks {
+		switch chunk.kind {
+		case "VP8X":
+			if len(chunk.data) >= 10 {
+				width = readUint24(chunk.data[4:7]) + 1
+				height = readUint24(chunk.data[7:10]) + 1
+			}
+		case "ANIM":
+			animated = true
+		}
+	}
+
+	if !animated {
+		img, err := webp.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		anim := &Animation{}
+		anim.append(img, 0)
+		return anim, nil
+	}
+
+	if err := checkCanvas(width, height); err != nil {
+		return nil, err
+	}
+
+	anim := &Animation{}
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	for _, chunk := range chunks {
+		if chunk.kind != "ANMF" || len(chunk.data) < 16 {
+			continue
+		}
+		x := readUint24(chunk.data[0:3]) * 2
+		y := readUint24(chunk.data[3:6]) * 2
+		frameWidth := readUint24(chunk.data[6:9]) + 1
+		frameHeight := readUint24(chunk.data[9:12]) + 1
+		delay := time.Duration(readUint24(chunk.data[12:15])) * time.Millisecond
+		flags := chunk.data[15]
+		noBlend := flags&0x02 != 0
+		disposeBackground := flags&0x01 != 0
+
+		subChunks, err := readRIFFChunks(chunk.data[16:])
+		if err != nil {
+			return nil, err
+		}
+
+		var body bytes.Buffer
+		var alpha []byte
+		for _, sub := range subChunks {
+			switch sub.kind {
+			case "ALPH":
+				alpha = sub.data
+			case "VP8 ", "VP8L":
+				if alpha != nil && sub.kind == "VP8 " {
+					vp8x := make([]byte, 10)
+					vp8x[0] = 0x10 // Alpha flag
+					vp8x[4], vp8x[5], vp8x[6] = byte(frameWidth-1), byte((frameWidth-1)>>8), byte((frameWidth-1)>>16)
+					vp8x[7], vp8x[8], vp8x[9] = byte(frameHeight-1), byte((frameHeight-1)>>8), byte((frameHeight-1)>>16)
+					writeRIFFChunk(&body, "VP8X", vp8x)
+					writeRIFFChunk(&body, "ALPH", alpha)
+				}
+				writeRIFFChunk(&body, sub.kind, sub.data)
+			}
+		}
+		if body.Len() == 0 {
+			continue
+		}
+
+		var file bytes.Buffer
+		file.WriteString("RIFF")
+		binary.Write(&file, binary.LittleEndian, uint32(body.Len()+4))
+		file.WriteString("WEBP")
+		file.Write(body.Bytes())
+
+		img, err := webp.Decode(&file)
+		if err != nil {
+			return nil, err
+		}
+
+		rect := image.Rect(x, y, x+frameWidth, y+frameHeight)
+		op := draw.Over
+		if noBlend {
+			op = draw.Src
+		}
+		draw.Draw(canvas, rect, img, img.Bounds().Min, op)
+		if !anim.append(cloneRGBA(canvas), delay) {
+			break
+		}
+
+		if disposeBackground {
+			draw.Draw(canvas, rect, &image.Uniform{C: color.Transparent}, image.Point{}, draw.Src)
+		}
+	}
+
+	if len(anim.Frames) == 0 {
+		return nil, errors.New("webp: no frames")
+	}
+	return anim, nil
+}
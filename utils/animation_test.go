@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+	"time"
+)
+
+var testPalette = color.Palette{
+	color.Transparent,
+	color.RGBA{R: 0xff, A: 0xff},
+	color.RGBA{G: 0xff, A: 0xff},
+}
+
+// Returns a frame of the given bounds filled with one palette index
+func testFrame(rect image.Rectangle, index uint8, palette color.Palette) *image.Paletted {
+	frame := image.NewPaletted(rect, palette)
+	for i := range frame.Pix {
+		frame.Pix[i] = index
+	}
+	return frame
+}
+
+func encodeGIF(t *testing.T, g *gif.GIF) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeGIFAnimation(t *testing.T) {
+	// The second frame covers part of the first and is removed afterwards, the
+	// third has a local color table
+	local := color.Palette{color.RGBA{B: 0xff, A: 0xff}, color.Transparent}
+	data := encodeGIF(t, &gif.GIF{
+		Image: []*image.Paletted{
+			testFrame(image.Rect(0, 0, 4, 4), 1, testPalette),
+			testFrame(image.Rect(2, 2, 4, 4), 2, testPalette),
+			testFrame(image.Rect(0, 0, 1, 1), 0, local),
+		},
+		Delay:    []int{5, 10, 0},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalPrevious, gif.DisposalNone},
+		Config: image.Config{
+			ColorModel: testPalette,
+			Width:      4,
+			Height:     4,
+		},
+	})
+
+	anim, err := DecodeAnimation(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Frames) != 3 {
+		t.Fatalf("got %d frames, want 3", len(anim.Frames))
+	}
+	wantDelays := []time.Duration{50 * time.Millisecond, 100 * time.Millisecond, defaultFrameDelay}
+	for i, want := range wantDelays {
+		if anim.Delays[i] != want {
+			t.Errorf("frame %d delay = %v, want %v", i, anim.Delays[i], want)
+		}
+	}
+
+	red := color.RGBA{R: 0xff, A: 0xff}
+	green := color.RGBA{G: 0xff, A: 0xff}
+	blue := color.RGBA{B: 0xff, A: 0xff}
+	tests := []struct {
+		frame int
+		x, y  int
+		want  color.RGBA
+	}{
+		{0, 3, 3, red},
+		{1, 0, 0, red},
+		{1, 3, 3, green},
+		// The second frame was disposed to the canvas before it
+		{2, 3, 3, red},
+		{2, 0, 0, blue},
+	}
+	for _, tt := range tests {
+		got := color.RGBAModel.Convert(anim.Frames[tt.frame].At(tt.x, tt.y))
+		if got != tt.want {
+			t.Errorf("frame %d pixel %d,%d = %v, want %v", tt.frame, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeGIFAnimationCaps(t *testing.T) {
+	tests := []struct {
+		name       string
+		size       int
+		frames     int
+		wantFrames int
+	}{
+		{"frame count", 2, MaxAnimationFrames + 50, MaxAnimationFrames},
+		{"pixels", 1024, 40, MaxAnimationPixels / (1024 * 1024)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &gif.GIF{Config: image.Config{ColorModel: testPalette, Width: tt.size, Height: tt.size}}
+			for i := range tt.frames {
+				g.Image = append(g.Image, testFrame(image.Rect(0, 0, 1, 1), uint8(i%2+1), testPalette))
+				g.Delay = append(g.Delay, 10)
+			}
+
+			anim, err := DecodeAnimation(encodeGIF(t, g))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(anim.Frames) != tt.wantFrames {
+				t.Errorf("got %d frames, want %d", len(anim.Frames), tt.wantFrames)
+			}
+		})
+	}
+}
+
+func TestDecodeGIFAnimationTooLarge(t *testing.T) {
+	data := encodeGIF(t, &gif.GIF{
+		Image:  []*image.Paletted{testFrame(image.Rect(0, 0, 1, 1), 1, testPalette)},
+		Delay:  []int{0},
+		Config: image.Config{ColorModel: testPalette, Width: 1, Height: 1},
+	})
+	// Claims a logical screen of 65535x65535 pixels
+	data[6], data[7], data[8], data[9] = 0xff, 0xff, 0xff, 0xff
+
+	if _, err := DecodeAnimation(data); err == nil {
+		t.Error("decoded a GIF with an oversized canvas")
+	}
+}
+
+func TestIsAPNG(t *testing.T) {
+	png := func(chunks ...string) []byte {
+		var buf bytes.Buffer
+		buf.WriteString(pngSignature)
+		for _, kind := range chunks {
+			writePNGChunk(&buf, kind, make([]byte, 13))
+		}
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"apng", png("IHDR", "acTL", "fcTL", "IDAT", "IEND"), true},
+		{"apng with ancillary chunks first", png("IHDR", "iCCP", "tEXt", "acTL", "IDAT"), true},
+		{"still", png("IHDR", "IDAT", "IEND"), false},
+		{"acTL after the image data", png("IHDR", "IDAT", "acTL"), false},
+		{"cut before acTL", png("IHDR", "tEXt", "acTL")[:40], false},
+		{"not a png", []byte("GIF89a"), false},
+	}
+	for _, tt := range tests {
+		if got := isAPNG(tt.data); got != tt.want {
+			t.Errorf("%s: isAPNG = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+)
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+func decodeBase83(s string) (int, error) {
+	value := 0
+	for _, r := range s {
+		digit := strings.IndexRune(base83Chars, r)
+		if digit == -1 {
+			return 0, fmt.Errorf("invalid base83 character %q", r)
+		}
+		value = value*83 + digit
+	}
+	return value, nil
+}
+
+func sRGBToLinear(value int) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(value float64) uint8 {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return uint8(math.Round(v * 12.92 * 255))
+	}
+	return uint8(math.Round((1.055*math.Pow(v, 1/2.4) - 0.055) * 255))
+}
+
+func signPow(value, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
+
+// Decodes a blurhash string into an image of the given size in pixels.
+// punch adjusts the contrast of the result, 1 being the neutral value
+func DecodeBlurhash(hash string, width, height int, punch float64) (image.Image, error) {
+	if len(hash) < 6 {
+		return nil, fmt.Errorf("blurhash too short: %d characters", len(hash))
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid blurhash size %dx%d", width, height)
+	}
+
+	sizeFlag, err := decodeBase83(hash[0:1])
+	if err != nil {
+		return nil, err
+	}
+	numX := sizeFlag%9 + 1
+	numY := sizeFlag/9 + 1
+	if len(hash) != 4+2*numX*numY {
+		return nil, fmt.Errorf("blurhash length mismatch: expected %d, got %d", 4+2*numX*numY, len(hash))
+	}
+
+	quantisedMax, err := decodeBase83(hash[1:2])
+	if err != nil {
+		return nil, err
+	}
+	maxValue := float64(quantisedMax+1) / 166
+
+	colors := make([][3]float64, numX*numY)
+	for i := range colors {
+		if i == 0 {
+			value, err := decodeBase83(hash[2:6])
+			if err != nil {
+				return nil, err
+			}
+			colors[i] = [3]float64{
+				sRGBToLinear(value >> 16),
+				sRGBToLinear((value >> 8) & 255),
+				sRGBToLinear(value & 255),
+			}
+			continue
+		}
+		value, err := decodeBase83(hash[4+i*2 : 6+i*2])
+		if err != nil {
+			return nil, err
+		}
+		quantR := value / (19 * 19)
+		quantG := (value / 19) % 19
+		quantB := value % 19
+		colors[i] = [3]float64{
+			signPow((float64(quantR)-9)/9, 2) * maxValue * punch,
+			signPow((float64(quantG)-9)/9, 2) * maxValue * punch,
+			signPow((float64(quantB)-9)/9, 2) * maxValue * punch,
+		}
+	}
+
+	// Precompute the cosine bases for every column and row
+	cosX := make([]float64, width*numX)
+	for x := range width {
+		for i := range numX {
+			cosX[x*numX+i] = math.Cos(math.Pi * float64(x) * float64(i) / float64(width))
+		}
+	}
+	cosY := make([]float64, height*numY)
+	for y := range height {
+		for j := range numY {
+			cosY[y*numY+j] = math.Cos(math.Pi * float64(y) * float64(j) / float64(height))
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			var r, g, b float64
+			for j := range numY {
+				basisY := cosY[y*numY+j]
+				for i := range numX {
+					basis := cosX[x*numX+i] * basisY
+					c := colors[i+j*numX]
+					r += c[0] * basis
+					g += c[1] * basis
+					b += c[2] * basis
+				}
+			}
+			img.SetRGBA(x, y, color.RGBA{
+				R: linearToSRGB(r),
+				G: linearToSRGB(g),
+				B: linearToSRGB(b),
+				A: 255,
+			})
+		}
+	}
+
+	return img, nil
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestDecodeBase83(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"0", 0},
+		{"A", 10},
+		{"a", 36},
+		{"~", 82},
+		{"10", 83},
+		{"~~", 6888},
+		{"#$", 5209},
+		{"HV6n", 0x979695},
+	}
+	for _, tt := range tests {
+		got, err := decodeBase83(tt.input)
+		if err != nil {
+			t.Errorf("decodeBase83(%q) error: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("decodeBase83(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+
+	if _, err := decodeBase83("a b"); err == nil {
+		t.Error("decodeBase83 accepted a space")
+	}
+}
+
+func TestDecodeBlurhash(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+		// Expected color of every pixel, checked for hashes with a single
+		// component only
+		want *color.RGBA
+	}{
+		{
+			name: "single component",
+			hash: "00TNoS",
+			want: &color.RGBA{R: 0xff, G: 0x80, B: 0x00, A: 0xff},
+		},
+		{
+			name: "single component with punctuation",
+			hash: "005?}k",
+			want: &color.RGBA{R: 0x33, G: 0x66, B: 0x99, A: 0xff},
+		},
+		{
+			// 'L' is 21, so 4 components across and 3 down
+			name: "four by three components",
+			hash: "LEHV6nWB2yk8pyo0adR*.7kCMdnj",
+		},
+		{
+			// '8' is 8, so 9 components across and 1 down
+			name: "nine by one components",
+			hash: "8" + "0" + "TNoS" + "~~~~~~~~~~~~~~~~",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := DecodeBlurhash(tt.hash, 8, 6, 1)
+			if err != nil {
+				t.Fatalf("DecodeBlurhash error: %v", err)
+			}
+			if bounds := img.Bounds(); bounds.Dx() != 8 || bounds.Dy() != 6 {
+				t.Fatalf("size = %dx%d, want 8x6", bounds.Dx(), bounds.Dy())
+			}
+			if tt.want == nil {
+				return
+			}
+			for y := range 6 {
+				for x := range 8 {
+					if got := color.RGBAModel.Convert(img.At(x, y)); got != *tt.want {
+						t.Fatalf("pixel %d,%d = %v, want %v", x, y, got, *tt.want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeBlurhashErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		hash          string
+		width, height int
+	}{
+		{"too short", "00TNo", 8, 8},
+		{"longer than the size flag allows", "00TNoS00", 8, 8},
+		{"shorter than the size flag allows", "LEHV6nWB2yk8pyo0adR*.7kCMdn", 8, 8},
+		{"invalid size flag", " 0TNoS", 8, 8},
+		{"invalid average color", "00TN o", 8, 8},
+		{"invalid component", "10TNoS0 ", 8, 8},
+		{"empty size", "00TNoS", 0, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeBlurhash(tt.hash, tt.width, tt.height, 1); err == nil {
+				t.Errorf("DecodeBlurhash(%q) returned no error", tt.hash)
+			}
+		})
+	}
+}
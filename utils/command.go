@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Splits a command line into arguments. Whitespace separates arguments except
+// inside single or double quotes, and a backslash escapes the next character
+// outside single quotes. No shell is involved, so nothing else is special
+func SplitCommand(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range command {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", command)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
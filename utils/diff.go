@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+type DiffKind int
+
+const (
+	DiffEqual DiffKind = iota
+	DiffDelete
+	DiffInsert
+)
+
+// DiffOp is a run of text kept, removed or added between two versions
+type DiffOp struct {
+	Kind DiffKind
+	Text string
+}
+
+// Splits text into words and the whitespace between them, so joining the
+// tokens gives back the text
+func diffTokens(text string) []string {
+	var tokens []string
+	start := 0
+	space := false
+	for i, r := range text {
+		if i > start && unicode.IsSpace(r) != space {
+			tokens = append(tokens, text[start:i])
+			start = i
+		}
+		space = unicode.IsSpace(r)
+	}
+	if start < len(text) {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens
+}
+
+// Returns the changes that turn old into new, word by word. Adjacent changes
+// of the same kind are merged
+func DiffWords(old, new string) []DiffOp {
+	a := diffTokens(old)
+	b := diffTokens(new)
+
+	// lcs[i][j] is the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []DiffOp
+	add := func(kind DiffKind, text string) {
+		if text == "" {
+			return
+		}
+		if n := len(ops); n > 0 && ops[n-1].Kind == kind {
+			ops[n-1].Text += text
+			return
+		}
+		ops = append(ops, DiffOp{Kind: kind, Text: text})
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			add(DiffEqual, a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			add(DiffDelete, a[i])
+			i++
+		default:
+			add(DiffInsert, b[j])
+			j++
+		}
+	}
+	add(DiffDelete, strings.Join(a[i:], ""))
+	add(DiffInsert, strings.Join(b[j:], ""))
+	return ops
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+// Writes ops as one string with removed text as [-text-] and added text as
+// {+text+}
+func diffMarkup(ops []DiffOp) string {
+	var b strings.Builder
+	for _, op := range ops {
+		switch op.Kind {
+		case DiffDelete:
+			b.WriteString("[-" + op.Text + "-]")
+		case DiffInsert:
+			b.WriteString("{+" + op.Text + "+}")
+		default:
+			b.WriteString(op.Text)
+		}
+	}
+	return b.String()
+}
+
+func TestDiffWords(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want string
+	}{
+		{"both empty", "", "", ""},
+		{"unchanged", "a b", "a b", "a b"},
+		{"all added", "", "hi there", "{+hi there+}"},
+		{"all removed", "hi", "", "[-hi-]"},
+		{"word replaced", "the quick fox", "the slow fox", "the [-quick-]{+slow+} fox"},
+		{"added at the end", "a", "a b", "a{+ b+}"},
+		{"whitespace changed", "a b", "a  b", "a[- -]{+  +}b"},
+		{"adjacent removals merged", "one two three", "three", "[-one two -]three"},
+		{"newlines", "one\ntwo", "one\n\ntwo", "one[-\n-]{+\n\n+}two"},
+		{"multibyte", "café ñ", "café n", "café [-ñ-]{+n+}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops := DiffWords(tt.old, tt.new)
+			if got := diffMarkup(ops); got != tt.want {
+				t.Errorf("DiffWords(%q, %q) = %q, want %q", tt.old, tt.new, got, tt.want)
+			}
+
+			// The kept and removed text give back old, the kept and added new
+			var old, new strings.Builder
+			for _, op := range ops {
+				if op.Kind != DiffInsert {
+					old.WriteString(op.Text)
+				}
+				if op.Kind != DiffDelete {
+					new.WriteString(op.Text)
+				}
+			}
+			if old.String() != tt.old || new.String() != tt.new {
+				t.Errorf("ops give back (%q, %q)", old.String(), new.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultDiskCacheLimit = 512 << 20
+
+	// How long a downloaded image is used without asking the server whether
+	// it changed, when the server does not say
+	diskCacheFreshness = 24 * time.Hour
+)
+
+// diskCache stores downloaded images by the hash of their content, with an
+// index from each URL to its content and the validators needed to revalidate
+// it with the server. Files are touched when used, and the least recently
+// used are removed once the cache grows over its limit
+type diskCache struct {
+	dir     string
+	limit   int64
+	size    atomic.Int64
+	pruning sync.Mutex
+}
+
+type diskEntry struct {
+	URL          string `json:"url"`
+	Hash         string `json:"hash"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// Expires is when the content has to be revalidated before use
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+// Reports whether the content can be used without revalidating it
+func (e *diskEntry) Fresh() bool {
+	return time.Now().Before(e.Expires)
+}
+
+func newDiskCache(dir string, limit int64) *diskCache {
+	if dir == "" {
+		return nil
+	}
+	for _, sub := range []string{"objects", "index"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil
+		}
+	}
+	d := &diskCache{dir: dir, limit: limit}
+	d.prune()
+	return d
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (d *diskCache) indexPath(url string) string {
+	return filepath.Join(d.dir, "index", hashHex([]byte(url))+".json")
+}
+
+func (d *diskCache) objectPath(hash string) string {
+	return filepath.Join(d.dir, "objects", hash)
+}
+
+// Returns when content fetched now has to be revalidated, following the
+// Cache-Control header of the response
+func freshUntil(header http.Header) time.Time {
+	now := time.Now()
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.TrimSpace(strings.ToLower(directive))
+		if directive == "no-cache" || directive == "no-store" {
+			return now
+		}
+		if value, ok := strings.CutPrefix(directive, "max-age="); ok {
+			if seconds, err := strconv.Atoi(value); err == nil {
+				return now.Add(time.Duration(seconds) * time.Second)
+			}
+		}
+	}
+	return now.Add(diskCacheFreshness)
+}
+
+// Returns the cached entry and content of a URL, marking them as used
+func (d *diskCache) Lookup(url string) (*diskEntry, []byte, bool) {
+	if d == nil {
+		return nil, nil, false
+	}
+	indexPath := d.indexPath(url)
+	raw, err := os.ReadFile(indexPath)
+	if err != nil {
+		return nil, nil, false
+	}
+	var entry diskEntry
+	if err := json.Unmarshal(raw, &entry); err != nil || entry.URL != url {
+		return nil, nil, false
+	}
+	objectPath := d.objectPath(entry.Hash)
+	data, err := os.ReadFile(objectPath)
+	if err != nil || hashHex(data) != entry.Hash {
+		// The content was pruned or damaged, so the index is of no use
+		os.Remove(indexPath)
+		return nil, nil, false
+	}
+
+	now := time.Now()
+	os.Chtimes(indexPath, now, now)
+	os.Chtimes(objectPath, now, now)
+	return &entry, data, true
+}
+
+// Stores the content of a URL along with its validators. Identical content
+// downloaded from different URLs is only written once
+func (d *diskCache) Store(url string, data []byte, header http.Header) error {
+	if d == nil {
+		return nil
+	}
+	entry := diskEntry{
+		URL:          url,
+		Hash:         hashHex(data),
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		Expires:      freshUntil(header),
+	}
+
+	objectPath := d.objectPath(entry.Hash)
+	if _, err := os.Stat(objectPath); err != nil {
+		if err := writeFileAtomic(objectPath, data); err != nil {
+			return err
+		}
+		d.size.Add(int64(len(data)))
+	}
+	return d.writeEntry(entry)
+}
+
+// Marks an entry the server confirmed unchanged as fresh again
+func (d *diskCache) Refresh(entry *diskEntry, header http.Header) error {
+	if d == nil {
+		return nil
+	}
+	refreshed := *entry
+	refreshed.Expires = freshUntil(header)
+	return d.writeEntry(refreshed)
+}
+
+func (d *diskCache) writeEntry(entry diskEntry) error {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(d.indexPath(entry.URL), raw); err != nil {
+		return err
+	}
+	d.size.Add(int64(len(raw)))
+
+	if d.size.Load() > d.limit {
+		go d.prune()
+	}
+	return nil
+}
+
+// Measures the cache and removes the least recently used files until it is
+// under 90% of its limit, so pruning does not run again on the next store
+func (d *diskCache) prune() {
+	if !d.pruning.TryLock() {
+		return
+	}
+	defer d.pruning.Unlock()
+
+	type cachedFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []cachedFile
+	var total int64
+	for _, sub := range []string{"objects", "index"} {
+		entries, err := os.ReadDir(filepath.Join(d.dir, sub))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			files = append(files, cachedFile{
+				path:    filepath.Join(d.dir, sub, entry.Name()),
+				size:    info.Size(),
+				modTime: info.ModTime(),
+			})
+			total += info.Size()
+		}
+	}
+
+	if total > d.limit {
+		slices.SortFunc(files, func(a, b cachedFile) int {
+			return a.modTime.Compare(b.modTime)
+		})
+		target := d.limit / 10 * 9
+		for _, file := range files {
+			if total <= target {
+				break
+			}
+			if os.Remove(file.path) == nil {
+				total -= file.size
+			}
+		}
+	}
+	d.size.Store(total)
+}
+
+// Writes through a temporary file so concurrent readers never see a partial file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
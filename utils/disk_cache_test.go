@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFreshUntil(t *testing.T) {
+	tests := []struct {
+		cacheControl string
+		want         time.Duration
+	}{
+		{"", diskCacheFreshness},
+		{"public, max-age=600", 10 * time.Minute},
+		{"max-age=0", 0},
+		{"no-cache", 0},
+		{"private, no-store", 0},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		header.Set("Cache-Control", tt.cacheControl)
+		got := time.Until(freshUntil(header))
+		if got > tt.want || got < tt.want-time.Minute {
+			t.Errorf("freshUntil(%q) = now + %v, want now + %v", tt.cacheControl, got, tt.want)
+		}
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	d := newDiskCache(t.TempDir(), 1<<20)
+	fresh := http.Header{}
+	fresh.Set("Cache-Control", "max-age=3600")
+	stale := http.Header{}
+	stale.Set("Cache-Control", "no-cache")
+
+	data := bytes.Repeat([]byte("a"), 1000)
+	if err := d.Store("https://example.com/a.png", data, fresh); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Store("https://example.com/b.png", data[:10], stale); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, got, ok := d.Lookup("https://example.com/a.png")
+	if !ok || !bytes.Equal(got, data) {
+		t.Fatal("stored image not found")
+	}
+	if !entry.Fresh() {
+		t.Error("image stored with max-age is not fresh")
+	}
+	if entry, _, ok := d.Lookup("https://example.com/b.png"); !ok || entry.Fresh() {
+		t.Error("image stored with no-cache is fresh")
+	}
+	if _, _, ok := d.Lookup("https://example.com/c.png"); ok {
+		t.Error("found an image that was never stored")
+	}
+}
+
+func TestDiskCachePrune(t *testing.T) {
+	// The limit is lowered after storing, so pruning only runs once
+	d := newDiskCache(t.TempDir(), 1<<20)
+	header := http.Header{}
+
+	urls := []string{"https://example.com/1", "https://example.com/2", "https://example.com/3"}
+	for i, url := range urls {
+		if err := d.Store(url, bytes.Repeat([]byte{byte(i)}, 1500), header); err != nil {
+			t.Fatal(err)
+		}
+		// Orders the files by use regardless of the timer resolution
+		used := time.Now().Add(time.Duration(i-10) * time.Minute)
+		entry, _, _ := d.Lookup(url)
+		os.Chtimes(d.indexPath(url), used, used)
+		os.Chtimes(d.objectPath(entry.Hash), used, used)
+	}
+	d.limit = 4000
+	d.prune()
+
+	if _, _, ok := d.Lookup(urls[0]); ok {
+		t.Error("the least recently used image was kept")
+	}
+	for _, url := range urls[1:] {
+		if _, _, ok := d.Lookup(url); !ok {
+			t.Errorf("%s was pruned", url)
+		}
+	}
+	if size := d.size.Load(); size > 4000 {
+		t.Errorf("cache size %d is over its limit", size)
+	}
+}
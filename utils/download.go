@@ -0,0 +1,228 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxImageBytes caps the size of a downloaded image
+	MaxImageBytes = 32 << 20
+	// MaxImagePixels caps the dimensions of an image before it is decoded,
+	// guarding against decompression bombs
+	MaxImagePixels = 8192 * 8192
+
+	downloadWorkers     = 8
+	downloadsPerHost    = 4
+	downloadTimeout     = 60 * time.Second
+	downloadDialTimeout = 10 * time.Second
+	// Saved files can be videos, which take longer than images
+	saveTimeout = 10 * time.Minute
+)
+
+// HTTPClient is shared by every media download
+var HTTPClient = &http.Client{
+	Timeout: downloadTimeout,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   downloadDialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   downloadDialTimeout,
+		ResponseHeaderTimeout: 20 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConnsPerHost:   downloadsPerHost,
+	},
+}
+
+type downloadJob struct {
+	key     string
+	url     string
+	host    string
+	ctx     context.Context
+	counted bool
+}
+
+// downloadPool runs jobs on a fixed set of workers, with at most perHost jobs
+// running against the same host at a time
+type downloadPool struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []*downloadJob
+	active  map[string]int
+	perHost int
+	run     func(*downloadJob)
+}
+
+func newDownloadPool(workers, perHost int, run func(*downloadJob)) *downloadPool {
+	p := &downloadPool{
+		active:  make(map[string]int),
+		perHost: perHost,
+		run:     run,
+	}
+	p.cond = sync.NewCond(&p.mu)
+	for range workers {
+		go p.work()
+	}
+	return p
+}
+
+// Queues a download of rawURL. The key identifies the job to the run callback
+func (p *downloadPool) Submit(ctx context.Context, key, rawURL string) {
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		host = u.Host
+	}
+
+	p.mu.Lock()
+	p.queue = append(p.queue, &downloadJob{key: key, url: rawURL, host: host, ctx: ctx})
+	p.mu.Unlock()
+	p.cond.Signal()
+}
+
+func (p *downloadPool) work() {
+	for {
+		job := p.next()
+		p.run(job)
+		p.done(job)
+	}
+}
+
+// Waits for the first queued job that can run. Cancelled jobs are returned
+// right away so their cleanup is not held back by a busy host
+func (p *downloadPool) next() *downloadJob {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		for i, job := range p.queue {
+			cancelled := job.ctx.Err() != nil
+			if !cancelled && p.active[job.host] >= p.perHost {
+				continue
+			}
+			p.queue = append(p.queue[:i], p.queue[i+1:]...)
+			if !cancelled {
+				p.active[job.host]++
+				job.counted = true
+			}
+			return job
+		}
+		p.cond.Wait()
+	}
+}
+
+func (p *downloadPool) done(job *downloadJob) {
+	if !job.counted {
+		return
+	}
+	p.mu.Lock()
+	p.active[job.host]--
+	if p.active[job.host] == 0 {
+		delete(p.active, job.host)
+	}
+	p.mu.Unlock()
+	p.cond.Broadcast()
+}
+
+// Wakes the workers so jobs cancelled while queued are cleaned up
+func (p *downloadPool) Wake() {
+	p.cond.Broadcast()
+}
+
+// Reads a response body, failing for good once it goes over limit bytes
+func readLimited(resp *http.Response, limit int64) ([]byte, error) {
+	if resp.ContentLength > limit {
+		return nil, permanentError{fmt.Errorf("image too large: %d bytes", resp.ContentLength)}
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, permanentError{fmt.Errorf("image too large: over %d bytes", limit)}
+	}
+	return data, nil
+}
+
+// Downloads rawURL into dir, named after the last element of its path. An
+// existing file is never overwritten, a number is added to the name instead.
+// Returns the path of the saved file
+func SaveFile(ctx context.Context, rawURL, dir string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	name := path.Base(u.Path)
+	if name == "." || name == "/" {
+		name = "media"
+	}
+
+	// Bounds the whole download, body included, in place of the shorter
+	// timeout of HTTPClient
+	ctx, cancel := context.WithTimeout(ctx, saveTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{Transport: HTTPClient.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download file: bad status code %d", resp.StatusCode)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 0; ; i++ {
+		target := filepath.Join(dir, name)
+		if i > 0 {
+			target = filepath.Join(dir, fmt.Sprintf("%s-%d%s", base, i, ext))
+		}
+		// Reserves the name first, so an existing file is never replaced
+		f, err := os.OpenFile(target, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		f.Close()
+		if err := os.Rename(tmp.Name(), target); err != nil {
+			os.Remove(target)
+			return "", err
+		}
+		return target, nil
+	}
+}
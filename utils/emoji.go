@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"strings"
+
+	"git.sr.ht/~rockorager/vaxis"
+	"github.com/mattn/go-mastodon"
+)
+
+var EmojiStyle = vaxis.Style{Foreground: vaxis.IndexColor(5)}
+
+// Builds a lookup of ":shortcode:" to image URL from the emoji lists of a
+// status or account
+func EmojiURLs(lists ...[]mastodon.Emoji) map[string]string {
+	urls := make(map[string]string)
+	for _, emojis := range lists {
+		for _, emoji := range emojis {
+			url := emoji.StaticURL
+			if url == "" {
+				url = emoji.URL
+			}
+			if emoji.ShortCode != "" && url != "" {
+				urls[":"+emoji.ShortCode+":"] = url
+			}
+		}
+	}
+	return urls
+}
+
+// Splits the text of the segments so every known ":shortcode:" is a segment
+// of its own, styled with EmojiStyle on top of its original style
+func SplitEmoji(segments []vaxis.Segment, emojis map[string]string) []vaxis.Segment {
+	if len(emojis) == 0 {
+		return segments
+	}
+
+	var result []vaxis.Segment
+	for _, seg := range segments {
+		text := seg.Text
+		for {
+			start, end := findEmoji(text, emojis)
+			if start == -1 {
+				break
+			}
+			if start > 0 {
+				result = append(result, vaxis.Segment{Text: text[:start], Style: seg.Style})
+			}
+			style := seg.Style
+			style.Foreground = EmojiStyle.Foreground
+			result = append(result, vaxis.Segment{Text: text[start:end], Style: style})
+			text = text[end:]
+		}
+		if text != "" {
+			result = append(result, vaxis.Segment{Text: text, Style: seg.Style})
+		}
+	}
+	return result
+}
+
+// Returns the bounds of the first known ":shortcode:" in text, or -1
+func findEmoji(text string, emojis map[string]string) (int, int) {
+	offset := 0
+	for {
+		start := strings.IndexByte(text[offset:], ':')
+		if start == -1 {
+			return -1, -1
+		}
+		start += offset
+		end := strings.IndexByte(text[start+1:], ':')
+		if end == -1 {
+			return -1, -1
+		}
+		end += start + 2
+		if _, ok := emojis[text[start:end]]; ok {
+			return start, end
+		}
+		// The closing colon may open the next shortcode
+		offset = end - 1
+	}
+}
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"image"
+	"image/color"
+
+	"git.sr.ht/~rockorager/vaxis"
+	"golang.org/x/image/draw"
+)
+
+// Pixels with less alpha than this are drawn as the terminal background
+const halfBlockAlphaThreshold = 0x4000
+
+// halfBlockImage draws an image with text cells, two pixels per cell using the
+// upper half block character with different foreground and background colors.
+// It works on any terminal with true color support
+type halfBlockImage struct {
+	img    image.Image
+	cells  []vaxis.Cell
+	width  int
+	height int
+}
+
+func newHalfBlockImage(img image.Image) *halfBlockImage {
+	hb := &halfBlockImage{img: img}
+	bounds := img.Bounds()
+	hb.Resize(bounds.Dx(), (bounds.Dy()+1)/2)
+	return hb
+}
+
+func (hb *halfBlockImage) Draw(win vaxis.Window) {
+	for i, cell := range hb.cells {
+		win.SetCell(i%hb.width, i/hb.width, cell)
+	}
+}
+
+func (hb *halfBlockImage) Destroy() {
+	hb.cells = nil
+}
+
+// Resizes the image to fit within w x h cells, keeping its aspect ratio. The
+// image is never upscaled
+func (hb *halfBlockImage) Resize(w int, h int) {
+	bounds := hb.img.Bounds()
+	pixelWidth, pixelHeight := bounds.Dx(), bounds.Dy()
+	if w <= 0 || h <= 0 || pixelWidth <= 0 || pixelHeight <= 0 {
+		hb.cells, hb.width, hb.height = nil, 0, 0
+		return
+	}
+
+	scale := min(float64(w)/float64(pixelWidth), float64(h*2)/float64(pixelHeight), 1)
+	targetWidth := max(int(float64(pixelWidth)*scale), 1)
+	targetHeight := max(int(float64(pixelHeight)*scale), 1)
+
+	scaled := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+	draw.BiLinear.Scale(scaled, scaled.Bounds(), hb.img, bounds, draw.Src, nil)
+
+	hb.width = targetWidth
+	hb.height = (targetHeight + 1) / 2
+	hb.cells = make([]vaxis.Cell, hb.width*hb.height)
+	for i := range hb.cells {
+		x := i % hb.width
+		y := i / hb.width * 2
+		top := scaled.RGBAAt(x, y)
+		bottom := color.RGBA{}
+		if y+1 < targetHeight {
+			bottom = scaled.RGBAAt(x, y+1)
+		}
+		hb.cells[i] = halfBlockCell(top, bottom)
+	}
+}
+
+func (hb *halfBlockImage) CellSize() (int, int) {
+	return hb.width, hb.height
+}
+
+func halfBlockCell(top, bottom color.RGBA) vaxis.Cell {
+	topVisible := uint32(top.A)*0x101 >= halfBlockAlphaThreshold
+	bottomVisible := uint32(bottom.A)*0x101 >= halfBlockAlphaThreshold
+
+	cell := vaxis.Cell{Character: vaxis.Character{Grapheme: " ", Width: 1}}
+	switch {
+	case topVisible && bottomVisible:
+		cell.Grapheme = "▀"
+		cell.Style.Foreground = rgbColor(top)
+		cell.Style.Background = rgbColor(bottom)
+	case topVisible:
+		cell.Grapheme = "▀"
+		cell.Style.Foreground = rgbColor(top)
+	case bottomVisible:
+		cell.Grapheme = "▄"
+		cell.Style.Foreground = rgbColor(bottom)
+	}
+	return cell
+}
+
+// Returns the color without its premultiplied alpha
+func rgbColor(c color.RGBA) vaxis.Color {
+	if c.A == 0 || c.A == 0xff {
+		return vaxis.RGBColor(c.R, c.G, c.B)
+	}
+	unpremultiply := func(v uint8) uint8 {
+		return uint8(min(uint32(v)*0xff/uint32(c.A), 0xff))
+	}
+	return vaxis.RGBColor(unpremultiply(c.R), unpremultiply(c.G), unpremultiply(c.B))
+}
@@ -0,0 +1,351 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"git.sr.ht/~rockorager/vaxis"
+	"github.com/mattn/go-mastodon"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+type listState struct {
+	ordered bool
+	number  int
+}
+
+type spanState struct {
+	invisible bool
+	ellipsis  bool
+	mention   bool
+}
+
+// statusRenderer keeps the state of the tokenizer walk over status HTML
+type statusRenderer struct {
+	segments  []vaxis.Segment
+	knownTags map[string]struct{}
+
+	bold      int
+	italic    int
+	code      int
+	strike    int
+	pre       int
+	quote     int
+	invisible int
+	mention   int
+
+	link     string
+	linkKind string
+	lists    []listState
+	spans    []spanState
+
+	// Newlines waiting to be written before the next text
+	breaks      int
+	atLineStart bool
+}
+
+const (
+	linkPlain   = "link"
+	linkMention = "mention"
+	linkHashtag = "hashtag"
+)
+
+// Parses an HTML string and converts it into a slice of styled segments
+func ParseStatus(content string, tags []mastodon.Tag) []vaxis.Segment {
+	r := &statusRenderer{
+		knownTags:   make(map[string]struct{}),
+		atLineStart: true,
+	}
+	for _, tag := range tags {
+		r.knownTags[strings.ToLower(tag.Name)] = struct{}{}
+	}
+
+	z := html.NewTokenizer(strings.NewReader(content))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		token := z.Token()
+		switch tt {
+		case html.TextToken:
+			r.text(token.Data)
+		case html.StartTagToken:
+			r.startTag(token)
+		case html.SelfClosingTagToken:
+			r.startTag(token)
+			if token.DataAtom != atom.Br {
+				r.endTag(token)
+			}
+		case html.EndTagToken:
+			r.endTag(token)
+		}
+	}
+
+	// Ensure the content ends with a blank line
+	if len(r.segments) > 0 {
+		r.segments = append(r.segments, vaxis.Segment{Text: "\n\n"})
+	}
+
+	return r.segments
+}
+
+func hasClass(token html.Token, class string) bool {
+	for _, attr := range token.Attr {
+		if attr.Key == "class" && slices.Contains(strings.Fields(attr.Val), class) {
+			return true
+		}
+	}
+	return false
+}
+
+func attrValue(token html.Token, key string) string {
+	for _, attr := range token.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// Requests n newlines before the next text. Breaks at the start of the
+// content are dropped and consecutive breaks never exceed a blank line
+func (r *statusRenderer) lineBreak(n int) {
+	if len(r.segments) == 0 {
+		return
+	}
+	r.breaks = min(max(r.breaks, n), 2)
+}
+
+func (r *statusRenderer) style() vaxis.Style {
+	var style vaxis.Style
+	if r.bold > 0 {
+		style.Attribute |= vaxis.AttrBold
+	}
+	if r.italic > 0 {
+		style.Attribute |= vaxis.AttrItalic
+	}
+	if r.strike > 0 {
+		style.Attribute |= vaxis.AttrStrikethrough
+	}
+	if r.code > 0 || r.pre > 0 {
+		style.Foreground = vaxis.IndexColor(6)
+	}
+	if r.link != "" {
+		style.Hyperlink = r.link
+		switch r.linkKind {
+		case linkMention, linkHashtag:
+			style.Foreground = vaxis.IndexColor(4)
+		default:
+			style.UnderlineStyle = vaxis.UnderlineSingle
+		}
+	}
+	return style
+}
+
+// Appends a segment, merging it with the previous one when both share a style
+func (r *statusRenderer) emit(text string, style vaxis.Style) {
+	if text == "" {
+		return
+	}
+	if n := len(r.segments); n > 0 {
+		last := &r.segments[n-1]
+		if last.Style == style && !strings.HasSuffix(last.Text, "\n") {
+			last.Text += text
+			return
+		}
+	}
+	r.segments = append(r.segments, vaxis.Segment{Text: text, Style: style})
+}
+
+// Writes pending newlines, and the quote and list indentation of a new line
+func (r *statusRenderer) flushBreaks() {
+	if r.breaks > 0 {
+		r.segments = append(r.segments, vaxis.Segment{Text: strings.Repeat("\n", r.breaks)})
+		r.breaks = 0
+		r.atLineStart = true
+	}
+	if r.atLineStart {
+		if r.quote > 0 {
+			r.emit(strings.Repeat("│ ", r.quote), vaxis.Style{Attribute: vaxis.AttrDim})
+		}
+		r.atLineStart = false
+	}
+}
+
+func (r *statusRenderer) text(data string) {
+	if r.invisible > 0 {
+		return
+	}
+
+	if r.pre > 0 {
+		for i, line := range strings.Split(data, "\n") {
+			if i > 0 {
+				r.segments = append(r.segments, vaxis.Segment{Text: "\n"})
+				r.atLineStart = true
+			}
+			if line != "" {
+				r.flushBreaks()
+				r.emit(line, r.style())
+			}
+		}
+		return
+	}
+
+	// Outside of <pre> newlines are just whitespace, as in a browser
+	data = strings.ReplaceAll(data, "\n", " ")
+	if r.atLineStart || r.breaks > 0 {
+		data = strings.TrimLeft(data, " \t")
+	}
+	if data == "" {
+		return
+	}
+	r.flushBreaks()
+	r.emit(data, r.style())
+}
+
+func (r *statusRenderer) startTag(token html.Token) {
+	switch token.DataAtom {
+	case atom.B, atom.Strong:
+		r.bold++
+	case atom.Em, atom.I:
+		r.italic++
+	case atom.Del, atom.S, atom.Strike:
+		r.strike++
+	case atom.Code:
+		r.code++
+	case atom.Pre:
+		r.lineBreak(2)
+		r.pre++
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		r.lineBreak(2)
+		r.bold++
+	case atom.P:
+		r.lineBreak(2)
+	case atom.Br:
+		if len(r.segments) > 0 {
+			r.breaks = min(r.breaks+1, 2)
+		}
+	case atom.Blockquote:
+		r.lineBreak(2)
+		r.quote++
+	case atom.Ul, atom.Ol:
+		if len(r.lists) == 0 {
+			r.lineBreak(2)
+		} else {
+			r.lineBreak(1)
+		}
+		list := listState{ordered: token.DataAtom == atom.Ol, number: 1}
+		if start := attrValue(token, "start"); start != "" {
+			fmt.Sscanf(start, "%d", &list.number)
+		}
+		r.lists = append(r.lists, list)
+	case atom.Li:
+		r.lineBreak(1)
+		r.flushBreaks()
+		depth := max(len(r.lists), 1)
+		bullet := "• "
+		if len(r.lists) > 0 {
+			list := &r.lists[len(r.lists)-1]
+			if list.ordered {
+				bullet = fmt.Sprintf("%d. ", list.number)
+				list.number++
+			}
+		}
+		r.emit(strings.Repeat("  ", depth-1)+bullet, vaxis.Style{})
+		r.atLineStart = false
+	case atom.A:
+		r.link = attrValue(token, "href")
+		switch {
+		case r.mention > 0 || hasClass(token, "u-url") && hasClass(token, "mention"):
+			r.linkKind = linkMention
+		case hasClass(token, "hashtag") || r.isKnownTag(r.link):
+			r.linkKind = linkHashtag
+		default:
+			r.linkKind = linkPlain
+		}
+	case atom.Span:
+		span := spanState{
+			invisible: hasClass(token, "invisible"),
+			ellipsis:  hasClass(token, "ellipsis"),
+			mention:   hasClass(token, "h-card"),
+		}
+		if span.invisible {
+			r.invisible++
+		}
+		if span.mention {
+			r.mention++
+		}
+		r.spans = append(r.spans, span)
+	}
+}
+
+func (r *statusRenderer) endTag(token html.Token) {
+	switch token.DataAtom {
+	case atom.B, atom.Strong:
+		r.bold = max(r.bold-1, 0)
+	case atom.Em, atom.I:
+		r.italic = max(r.italic-1, 0)
+	case atom.Del, atom.S, atom.Strike:
+		r.strike = max(r.strike-1, 0)
+	case atom.Code:
+		r.code = max(r.code-1, 0)
+	case atom.Pre:
+		r.pre = max(r.pre-1, 0)
+		r.lineBreak(2)
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		r.bold = max(r.bold-1, 0)
+		r.lineBreak(2)
+	case atom.P:
+		r.lineBreak(2)
+	case atom.Blockquote:
+		r.quote = max(r.quote-1, 0)
+		r.lineBreak(2)
+	case atom.Ul, atom.Ol:
+		if len(r.lists) > 0 {
+			r.lists = r.lists[:len(r.lists)-1]
+		}
+		if len(r.lists) == 0 {
+			r.lineBreak(2)
+		} else {
+			r.lineBreak(1)
+		}
+	case atom.Li:
+		r.lineBreak(1)
+	case atom.A:
+		r.link = ""
+		r.linkKind = ""
+	case atom.Span:
+		if len(r.spans) == 0 {
+			return
+		}
+		span := r.spans[len(r.spans)-1]
+		r.spans = r.spans[:len(r.spans)-1]
+		if span.invisible {
+			r.invisible = max(r.invisible-1, 0)
+		}
+		if span.mention {
+			r.mention = max(r.mention-1, 0)
+		}
+		if span.ellipsis && r.invisible == 0 {
+			r.emit("…", r.style())
+		}
+	}
+}
+
+// Reports whether a link points to one of the hashtags of the status
+func (r *statusRenderer) isKnownTag(link string) bool {
+	if len(r.knownTags) == 0 || !IsTagLink(link) {
+		return false
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	_, ok := r.knownTags[strings.ToLower(path.Base(u.Path))]
+	return ok
+}
@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"git.sr.ht/~rockorager/vaxis"
+	"github.com/mattn/go-mastodon"
+)
+
+// Writes segments as text with their styles marked, so the expected output of
+// a case reads as one string: **bold**, _italic_, ~~struck~~, `code`, ((dim)),
+// [text](url) for links and {text}(url) for mentions and hashtags
+func markup(segs []vaxis.Segment) string {
+	var b strings.Builder
+	for _, seg := range segs {
+		text := seg.Text
+		style := seg.Style
+		if style.Foreground == vaxis.IndexColor(6) {
+			text = "`" + text + "`"
+		}
+		if style.Attribute&vaxis.AttrStrikethrough != 0 {
+			text = "~~" + text + "~~"
+		}
+		if style.Attribute&vaxis.AttrItalic != 0 {
+			text = "_" + text + "_"
+		}
+		if style.Attribute&vaxis.AttrBold != 0 {
+			text = "**" + text + "**"
+		}
+		if style.Attribute&vaxis.AttrDim != 0 {
+			text = "((" + text + "))"
+		}
+		switch {
+		case style.Hyperlink != "" && style.Foreground == vaxis.IndexColor(4):
+			text = "{" + text + "}(" + style.Hyperlink + ")"
+		case style.Hyperlink != "":
+			text = "[" + text + "](" + style.Hyperlink + ")"
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		tags    []mastodon.Tag
+		want    string
+	}{
+		{
+			name:    "empty",
+			content: "",
+			want:    "",
+		},
+		{
+			name:    "paragraphs",
+			content: `<p>First paragraph</p><p>Second paragraph</p>`,
+			want:    "First paragraph\n\nSecond paragraph\n\n",
+		},
+		{
+			name:    "line breaks",
+			content: `<p>one<br>two<br /><br />three<br><br><br>four</p><p>five</p>`,
+			want:    "one\ntwo\n\nthree\n\nfour\n\nfive\n\n",
+		},
+		{
+			name:    "newlines in text are spaces",
+			content: "<p>soft\nwrapped\ntext</p>",
+			want:    "soft wrapped text\n\n",
+		},
+		{
+			name:    "entities",
+			content: `<p>Fish &amp; chips &lt;3 it&#39;s &quot;great&quot;</p>`,
+			want:    "Fish & chips <3 it's \"great\"\n\n",
+		},
+		{
+			name:    "mention",
+			content: `<p><span class="h-card" translate="no"><a href="https://mastodon.social/@Gargron" class="u-url mention">@<span>Gargron</span></a></span> hello there</p>`,
+			want:    "{@Gargron}(https://mastodon.social/@Gargron) hello there\n\n",
+		},
+		{
+			name:    "remote mention",
+			content: `<p><span class="h-card" translate="no"><a href="https://fosstodon.org/@someone" class="u-url mention">@<span>someone</span></a></span> <span class="h-card" translate="no"><a href="https://hachyderm.io/@other" class="u-url mention">@<span>other</span></a></span> hi</p>`,
+			want:    "{@someone}(https://fosstodon.org/@someone) {@other}(https://hachyderm.io/@other) hi\n\n",
+		},
+		{
+			name:    "hashtag",
+			content: `<p>Writing <a href="https://mastodon.social/tags/golang" class="mention hashtag" rel="tag">#<span>golang</span></a> today</p>`,
+			want:    "Writing {#golang}(https://mastodon.social/tags/golang) today\n\n",
+		},
+		{
+			name:    "hashtag known from the tags of the status",
+			content: `<p><a href="https://example.social/tags/Go" rel="tag">#Go</a></p>`,
+			tags:    []mastodon.Tag{{Name: "go"}},
+			want:    "{#Go}(https://example.social/tags/Go)\n\n",
+		},
+		{
+			name:    "shortened link",
+			content: `<p>See <a href="https://example.com/some/very/long/path" target="_blank" rel="nofollow noopener noreferrer" translate="no"><span class="invisible">https://</span><span class="ellipsis">example.com/some/very/lo</span><span class="invisible">ng/path</span></a></p>`,
+			want:    "See [example.com/some/very/lo…](https://example.com/some/very/long/path)\n\n",
+		},
+		{
+			name:    "link without ellipsis",
+			content: `<p><a href="https://example.com/a" rel="nofollow noopener noreferrer" target="_blank"><span class="invisible">https://</span><span class="">example.com/a</span><span class="invisible"></span></a></p>`,
+			want:    "[example.com/a](https://example.com/a)\n\n",
+		},
+		{
+			name:    "inline styles",
+			content: `<p><strong>bold</strong> <em>italic</em> <b><i>both</i></b> <code>x := 1</code></p>`,
+			want:    "**bold** _italic_ **_both_** `x := 1`\n\n",
+		},
+		{
+			name:    "strikethrough",
+			content: `<p>This is <del>wrong</del> right, <s>also</s> <strike>gone</strike></p>`,
+			want:    "This is ~~wrong~~ right, ~~also~~ ~~gone~~\n\n",
+		},
+		{
+			name:    "headings",
+			content: `<h1>Title</h1><p>Body</p><h3>Section</h3><p>More</p>`,
+			want:    "**Title**\n\nBody\n\n**Section**\n\nMore\n\n",
+		},
+		{
+			name:    "unordered list",
+			content: `<p>Items:</p><ul><li>one</li><li>two</li></ul><p>after</p>`,
+			want:    "Items:\n\n• one\n• two\n\nafter\n\n",
+		},
+		{
+			name:    "ordered list with start",
+			content: `<ol start="3"><li>three</li><li>four</li></ol>`,
+			want:    "3. three\n4. four\n\n",
+		},
+		{
+			name:    "nested list",
+			content: `<ul><li>a<ul><li>b</li><li>c</li></ul></li><li>d</li></ul>`,
+			want:    "• a\n  • b\n  • c\n• d\n\n",
+		},
+		{
+			name:    "preformatted",
+			content: "<p>Code:</p><pre><code>func main() {\n\tfmt.Println(&quot;hi&quot;)\n}</code></pre><p>done</p>",
+			want:    "Code:\n\n`func main() {`\n`\tfmt.Println(\"hi\")`\n`}`\n\ndone\n\n",
+		},
+		{
+			name:    "blockquote",
+			content: `<blockquote><p>quoted</p><p>more</p></blockquote><p>reply</p>`,
+			want:    "((│ ))quoted\n\n((│ ))more\n\nreply\n\n",
+		},
+		{
+			name:    "nested blockquote",
+			content: `<blockquote><p>outer</p><blockquote><p>inner</p></blockquote></blockquote>`,
+			want:    "((│ ))outer\n\n((│ │ ))inner\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := markup(ParseStatus(tt.content, tt.tags))
+			if got != tt.want {
+				t.Errorf("ParseStatus(%q)\n got: %q\nwant: %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,140 +2,762 @@ package utils
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"image"
+	"image/draw"
 	"io"
 	"log"
 	"math"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	xdraw "golang.org/x/image/draw"
 	_ "golang.org/x/image/webp"
 
 	"git.sr.ht/~rockorager/vaxis"
+	"github.com/AbeEstrada/tuit/config"
 )
 
+const (
+	DefaultRawCacheLimit    = 128 << 20
+	DefaultScaledCacheLimit = 64 << 20
+
+	// Raw cache keys of decoded animations carry this prefix, so the still and
+	// animated versions of the same URL are cached separately
+	animationKeyPrefix = "anim:"
+	// Downloads that only check whether a PNG is animated carry this prefix
+	probeKeyPrefix = "probe:"
+	// How much of a PNG is fetched to find whether it is animated
+	pngProbeBytes = 64 << 10
+	// Memory limit in bytes for the URLs of PNGs known to be animated or not
+	probeCacheLimit = 1 << 20
+
+	// Blurhashes are decoded with this many pixels on their longer side, then
+	// scaled up. They hold no detail, so decoding them larger only costs time
+	blurhashDecodeSize = 32
+
+	// How long an image that failed to download for a reason that may pass,
+	// such as a timeout or a server error, waits before it is tried again
+	failedRetryDelay = time.Minute
+)
+
+type ImageCacheOptions struct {
+	// RawLimit is the memory limit in bytes for decoded images
+	RawLimit int64
+	// ScaledLimit is the memory limit in bytes for images scaled for the terminal
+	ScaledLimit int64
+	// DiskDir is where downloaded images are kept between runs, empty to disable
+	DiskDir string
+	// DiskLimit is the size limit in bytes of the images kept on disk
+	DiskLimit int64
+	// Protocol is one of the config.ImageProtocol values, empty for auto
+	Protocol string
+}
+
+type ImageCacheStats struct {
+	Hits        int64
+	Misses      int64
+	DiskHits    int64
+	Revalidated int64
+	Downloads   int64
+	RawBytes    int64
+	RawLimit    int64
+	ScaledBytes int64
+	ScaledLimit int64
+}
+
+func (s ImageCacheStats) String() string {
+	return fmt.Sprintf(
+		"Images: %d hits · %d misses · %d from disk (%d revalidated) · %d downloads · %s/%s raw · %s/%s scaled",
+		s.Hits, s.Misses, s.DiskHits, s.Revalidated, s.Downloads,
+		FormatBytes(s.RawBytes), FormatBytes(s.RawLimit),
+		FormatBytes(s.ScaledBytes), FormatBytes(s.ScaledLimit),
+	)
+}
+
 type GlobalImageCache struct {
-	mu          sync.RWMutex
-	rawCache    map[string]image.Image
-	scaledCache map[string]vaxis.Image
-	loading     map[string]bool
-	vx          *vaxis.Vaxis
+	mu          sync.Mutex
+	rawCache    *lruCache[image.Image]
+	scaledCache *lruCache[vaxis.Image]
+	apngCache   *lruCache[bool]
+	loading     map[string]context.CancelFunc
+	wanted      map[string]struct{}
+	// Keys of images that failed to load, with when they may be tried again.
+	// A zero time marks failures that trying again would not fix
+	failed map[string]time.Time
+	// Scaled images evicted while drawing the current frame, and those
+	// evicted during the one before. They may still be on screen, so they
+	// are only destroyed after a frame is drawn without them
+	evicted  []vaxis.Image
+	retiring []vaxis.Image
+
+	downloads  *downloadPool
+	disk       *diskCache
+	vx         *vaxis.Vaxis
+	protocol   string
+	paused     bool
+	cellWidth  int
+	cellHeight int
+
+	hits        atomic.Int64
+	misses      atomic.Int64
+	diskHits    atomic.Int64
+	revalidated atomic.Int64
+	downloaded  atomic.Int64
 }
 
 var ImageCache *GlobalImageCache
 var once sync.Once
 
-func InitImageCache(vx *vaxis.Vaxis) {
+func InitImageCache(vx *vaxis.Vaxis, opts ImageCacheOptions) {
 	once.Do(func() {
+		if opts.RawLimit <= 0 {
+			opts.RawLimit = DefaultRawCacheLimit
+		}
+		if opts.ScaledLimit <= 0 {
+			opts.ScaledLimit = DefaultScaledCacheLimit
+		}
+		if opts.DiskLimit <= 0 {
+			opts.DiskLimit = DefaultDiskCacheLimit
+		}
 		ImageCache = &GlobalImageCache{
-			rawCache:    make(map[string]image.Image),
-			scaledCache: make(map[string]vaxis.Image),
-			loading:     make(map[string]bool),
-			vx:          vx,
+			rawCache:   newLRUCache[image.Image](opts.RawLimit, nil),
+			apngCache:  newLRUCache[bool](probeCacheLimit, nil),
+			loading:    make(map[string]context.CancelFunc),
+			wanted:     make(map[string]struct{}),
+			failed:     make(map[string]time.Time),
+			disk:       newDiskCache(opts.DiskDir, opts.DiskLimit),
+			vx:         vx,
+			protocol:   opts.Protocol,
+			cellWidth:  10,
+			cellHeight: 20,
 		}
+		ImageCache.scaledCache = newLRUCache(opts.ScaledLimit, func(_ string, img vaxis.Image) {
+			// Called with the lock held, while a frame is drawn
+			ImageCache.evicted = append(ImageCache.evicted, img)
+		})
+		ImageCache.downloads = newDownloadPool(downloadWorkers, downloadsPerHost, ImageCache.runDownload)
 	})
 }
 
-func (c *GlobalImageCache) Get(url string, width, height int) (vaxis.Image, bool) {
-	c.mu.RLock()
+// Estimates the memory used by a decoded image
+func rawImageSize(img image.Image) int64 {
+	bounds := img.Bounds()
+	return int64(bounds.Dx()) * int64(bounds.Dy()) * 4
+}
 
-	cacheKey := fmt.Sprintf("%s|%d|%d", url, width, height)
-	if img, ok := c.scaledCache[cacheKey]; ok {
-		c.mu.RUnlock()
+// Estimates the memory used by an image encoded for the terminal, which is
+// roughly the pixels of the cells it covers
+func (c *GlobalImageCache) scaledImageSize(img vaxis.Image) int64 {
+	w, h := img.CellSize()
+	return int64(w*c.cellWidth) * int64(h*c.cellHeight) * 4
+}
+
+// Creates a terminal image with the configured protocol. Auto uses the
+// protocol detected by vaxis, or half blocks when the terminal supports
+// neither kitty nor sixel graphics
+func (c *GlobalImageCache) newImage(img image.Image) (vaxis.Image, error) {
+	switch c.protocol {
+	case config.ImageProtocolKitty:
+		return c.vx.NewKittyGraphic(img), nil
+	case config.ImageProtocolSixel:
+		return c.vx.NewSixel(img), nil
+	case config.ImageProtocolHalfBlock:
+		return newHalfBlockImage(img), nil
+	case config.ImageProtocolNone:
+		return nil, errors.New("images are disabled")
+	default:
+		if c.vx.CanDisplayGraphics() {
+			return c.vx.NewImage(img)
+		}
+		return newHalfBlockImage(img), nil
+	}
+}
+
+// Reports whether images are drawn at all. When they are not, callers show
+// text in their place
+func (c *GlobalImageCache) Enabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enabledLocked()
+}
+
+func (c *GlobalImageCache) enabledLocked() bool {
+	return c.protocol != config.ImageProtocolNone && !c.paused
+}
+
+// Turns image loading off or back on for the rest of the session, returning
+// whether images are now enabled
+func (c *GlobalImageCache) ToggleLoading() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = !c.paused
+	return c.enabledLocked()
+}
+
+// Updates the size of a terminal cell in pixels, used to decode placeholders
+// at the resolution they will be drawn at
+func (c *GlobalImageCache) SetCellSize(width, height int) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+	c.mu.Lock()
+	c.cellWidth = width
+	c.cellHeight = height
+	c.mu.Unlock()
+}
+
+// Returns the blurhash placeholder decoded to fill width x height cells
+func (c *GlobalImageCache) GetBlurhash(hash string, width, height int) (vaxis.Image, bool) {
+	if hash == "" || width <= 0 || height <= 0 {
+		return nil, false
+	}
+
+	cacheKey := fmt.Sprintf("blurhash:%s|%d|%d", hash, width, height)
+
+	c.mu.Lock()
+	enabled := c.enabledLocked()
+	img, ok := c.scaledCache.Get(cacheKey)
+	cellWidth, cellHeight := c.cellWidth, c.cellHeight
+	c.mu.Unlock()
+	if !enabled {
+		return nil, false
+	}
+	if ok {
 		return img, true
 	}
 
-	rawImg, hasRaw := c.rawCache[url]
-	c.mu.RUnlock()
+	// Terminal renderers only scale images down, so the small decode is
+	// scaled up to the pixels of the cells it covers
+	pixelWidth, pixelHeight := width*cellWidth, height*cellHeight
+	scale := float64(blurhashDecodeSize) / float64(max(pixelWidth, pixelHeight))
+	small, err := DecodeBlurhash(hash, max(int(float64(pixelWidth)*scale), 1), max(int(float64(pixelHeight)*scale), 1), 1)
+	if err != nil {
+		log.Printf("Error decoding blurhash %s: %v", hash, err)
+		return nil, false
+	}
+	rawImg := image.NewRGBA(image.Rect(0, 0, pixelWidth, pixelHeight))
+	xdraw.BiLinear.Scale(rawImg, rawImg.Bounds(), small, small.Bounds(), xdraw.Src, nil)
 
-	if !hasRaw {
-		c.LoadAsync(url)
+	vxImage, err := c.newImage(rawImg)
+	if err != nil {
+		log.Printf("Error creating vaxis image from blurhash %s: %v", hash, err)
 		return nil, false
 	}
+	vxImage.Resize(width, height)
 
+	c.mu.Lock()
+	c.scaledCache.Add(cacheKey, vxImage, c.scaledImageSize(vxImage))
+	c.mu.Unlock()
+
+	return vxImage, true
+}
+
+func (c *GlobalImageCache) Get(url string, width, height int) (vaxis.Image, bool) {
+	cacheKey := fmt.Sprintf("%s|%d|%d", url, width, height)
+	return c.getScaled(url, cacheKey, nil, func(vxImage vaxis.Image, rawImg image.Image) {
+		vxImage.Resize(coverSize(rawImg, width, height))
+	})
+}
+
+// Returns the size that makes the image cover width x height cells
+func coverSize(img image.Image, width, height int) (int, int) {
+	originalBounds := img.Bounds()
+	originalWidth, originalHeight := originalBounds.Dx(), originalBounds.Dy()
+
+	scaleFactor := math.Max(float64(width)/float64(originalWidth), float64(height)/float64(originalHeight))
+	return int(float64(originalWidth) * scaleFactor), int(float64(originalHeight) * scaleFactor)
+}
+
+// Returns the image resized to fit within width x height cells, keeping its
+// aspect ratio. Used for small inline images such as custom emoji
+func (c *GlobalImageCache) GetFitted(url string, width, height int) (vaxis.Image, bool) {
+	cacheKey := fmt.Sprintf("fit:%s|%d|%d", url, width, height)
+	return c.getScaled(url, cacheKey, nil, func(vxImage vaxis.Image, _ image.Image) {
+		vxImage.Resize(width, height)
+	})
+}
+
+// Returns the image cropped around its center to the shape of width x height
+// cells and resized to fill them
+func (c *GlobalImageCache) GetFilled(url string, width, height int) (vaxis.Image, bool) {
+	cacheKey := fmt.Sprintf("fill:%s|%d|%d", url, width, height)
+	crop := func(rawImg image.Image) image.Image {
+		// Called with the lock held, so the cell size can be read directly
+		return cropToAspect(rawImg, width*c.cellWidth, height*c.cellHeight)
+	}
+	return c.getScaled(url, cacheKey, crop, func(vxImage vaxis.Image, _ image.Image) {
+		vxImage.Resize(width, height)
+	})
+}
+
+// Returns the largest centered part of img with the aspect ratio of width x height
+func cropToAspect(img image.Image, width, height int) image.Image {
+	bounds := img.Bounds()
+	cropWidth, cropHeight := bounds.Dx(), bounds.Dy()
+	if cropWidth*height > cropHeight*width {
+		cropWidth = cropHeight * width / height
+	} else {
+		cropHeight = cropWidth * height / width
+	}
+	if cropWidth <= 0 || cropHeight <= 0 {
+		return img
+	}
+
+	x := bounds.Min.X + (bounds.Dx()-cropWidth)/2
+	y := bounds.Min.Y + (bounds.Dy()-cropHeight)/2
+	rect := image.Rect(x, y, x+cropWidth, y+cropHeight)
+
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(rect)
+	}
+	cropped := image.NewRGBA(image.Rect(0, 0, cropWidth, cropHeight))
+	draw.Draw(cropped, cropped.Bounds(), img, rect.Min, draw.Src)
+	return cropped
+}
+
+// Returns the scaled image stored under cacheKey, creating it from the raw
+// image, optionally cropped, with resize when only the raw image is cached.
+// Starts loading the raw image when it is not cached either
+func (c *GlobalImageCache) getScaled(url, cacheKey string, crop func(image.Image) image.Image, resize func(vaxis.Image, image.Image)) (vaxis.Image, bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if img, ok := c.scaledCache[cacheKey]; ok {
+	if !c.enabledLocked() {
+		return nil, false
+	}
+
+	if img, ok := c.scaledCache.Get(cacheKey); ok {
+		c.hits.Add(1)
 		return img, true
 	}
 
-	vxImage, err := c.vx.NewImage(rawImg)
+	rawImg, hasRaw := c.rawCache.Get(url)
+	if !hasRaw {
+		c.misses.Add(1)
+		c.wanted[url] = struct{}{}
+		c.loadAsyncLocked(url, url)
+		return nil, false
+	}
+	c.hits.Add(1)
+
+	if crop != nil {
+		rawImg = crop(rawImg)
+	}
+
+	vxImage, err := c.newImage(rawImg)
 	if err != nil {
 		log.Printf("Error creating vaxis image from cached raw %s: %v", url, err)
 		return nil, false
 	}
+	resize(vxImage, rawImg)
 
-	originalBounds := rawImg.Bounds()
-	originalWidth, originalHeight := originalBounds.Dx(), originalBounds.Dy()
+	c.scaledCache.Add(cacheKey, vxImage, c.scaledImageSize(vxImage))
 
-	scaleFactor := math.Max(float64(width)/float64(originalWidth), float64(height)/float64(originalHeight))
-	scaledWidth := int(float64(originalWidth) * scaleFactor)
-	scaledHeight := int(float64(originalHeight) * scaleFactor)
+	return vxImage, true
+}
 
-	vxImage.Resize(scaledWidth, scaledHeight)
+// Returns the animation at url scaled to cover width x height cells like Get.
+// Still images come back as a single frame animation
+func (c *GlobalImageCache) GetAnimation(url string, width, height int) (*AnimatedImage, bool) {
+	key := animationKeyPrefix + url
+	cacheKey := fmt.Sprintf("%s|%d|%d", key, width, height)
 
-	c.scaledCache[cacheKey] = vxImage
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	return vxImage, true
+	if !c.enabledLocked() {
+		return nil, false
+	}
+
+	if img, ok := c.scaledCache.Get(cacheKey); ok {
+		c.hits.Add(1)
+		return img.(*AnimatedImage), true
+	}
+
+	rawImg, hasRaw := c.rawCache.Get(key)
+	if !hasRaw {
+		c.misses.Add(1)
+		c.wanted[key] = struct{}{}
+		c.loadAsyncLocked(key, url)
+		return nil, false
+	}
+	c.hits.Add(1)
+
+	anim := rawImg.(*Animation)
+	animated := &AnimatedImage{delays: anim.Delays}
+	scaledWidth, scaledHeight := coverSize(anim, width, height)
+	for _, frame := range anim.Frames {
+		vxImage, err := c.newImage(frame)
+		if err != nil {
+			log.Printf("Error creating vaxis image from animation %s: %v", url, err)
+			animated.Destroy()
+			return nil, false
+		}
+		vxImage.Resize(scaledWidth, scaledHeight)
+		animated.frames = append(animated.frames, vxImage)
+	}
+
+	c.scaledCache.Add(cacheKey, animated, c.scaledImageSize(animated)*int64(len(animated.frames)))
+
+	return animated, true
+}
+
+// Reports whether images are drawn with real graphics, as opposed to cell
+// based approximations
+func (c *GlobalImageCache) CanDisplayGraphics() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.enabledLocked() {
+		return false
+	}
+	switch c.protocol {
+	case config.ImageProtocolKitty, config.ImageProtocolSixel:
+		return true
+	case config.ImageProtocolHalfBlock:
+		return false
+	default:
+		return c.vx.CanDisplayGraphics()
+	}
+}
+
+func (c *GlobalImageCache) Stats() ImageCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return ImageCacheStats{
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		DiskHits:    c.diskHits.Load(),
+		Revalidated: c.revalidated.Load(),
+		Downloads:   c.downloaded.Load(),
+		RawBytes:    c.rawCache.Size(),
+		RawLimit:    c.rawCache.limit,
+		ScaledBytes: c.scaledCache.Size(),
+		ScaledLimit: c.scaledCache.limit,
+	}
 }
 
 func (c *GlobalImageCache) LoadAsync(url string) {
 	c.mu.Lock()
-	if c.loading[url] {
-		c.mu.Unlock()
+	defer c.mu.Unlock()
+	if !c.enabledLocked() {
 		return
 	}
-	if _, ok := c.rawCache[url]; ok {
-		c.mu.Unlock()
+	c.wanted[url] = struct{}{}
+	c.loadAsyncLocked(url, url)
+}
+
+// Starts loading url into the raw cache under key. URLs that failed before
+// are only retried once their failure may have passed
+func (c *GlobalImageCache) loadAsyncLocked(key, url string) {
+	if _, ok := c.loading[key]; ok || c.rawCache.Contains(key) {
 		return
 	}
+	if retry, ok := c.failed[key]; ok {
+		if retry.IsZero() || time.Now().Before(retry) {
+			return
+		}
+		delete(c.failed, key)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.loading[key] = cancel
+	c.downloads.Submit(ctx, key, url)
+}
 
-	c.loading[url] = true
+// Marks the end of a frame. Downloads of images that were not requested while
+// drawing it are no longer in view, so they are cancelled. Images evicted
+// before the frame are no longer on screen, so they are destroyed
+func (c *GlobalImageCache) EndFrame() {
+	c.mu.Lock()
+	retired := c.retiring
+	c.retiring, c.evicted = c.evicted, nil
+	cancelled := false
+	for key, cancel := range c.loading {
+		if _, ok := c.wanted[key]; !ok {
+			cancel()
+			cancelled = true
+		}
+	}
+	clear(c.wanted)
 	c.mu.Unlock()
 
-	go func() {
-		defer func() {
-			c.mu.Lock()
-			delete(c.loading, url)
-			c.mu.Unlock()
-			c.vx.PostEvent(vaxis.Redraw{})
-		}()
+	for _, img := range retired {
+		// Frees the image from the terminal memory as well
+		img.Destroy()
+	}
+	if cancelled {
+		c.downloads.Wake()
+	}
+}
 
-		img, err := DownloadImage(url)
-		if err != nil {
-			log.Printf("Error downloading image %s: %v", url, err)
-			return
+func (c *GlobalImageCache) runDownload(job *downloadJob) {
+	defer func() {
+		c.mu.Lock()
+		if cancel, ok := c.loading[job.key]; ok {
+			cancel()
+			delete(c.loading, job.key)
 		}
+		c.mu.Unlock()
+		if job.ctx.Err() == nil {
+			c.vx.PostEvent(vaxis.Redraw{})
+		}
+	}()
 
+	if job.ctx.Err() != nil {
+		return
+	}
+
+	var err error
+	if strings.HasPrefix(job.key, probeKeyPrefix) {
+		err = c.probeAPNG(job)
+	} else {
+		err = c.loadImage(job)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Printf("Error downloading image %s: %v", job.url, err)
+		var retry time.Time
+		if !errors.As(err, new(permanentError)) {
+			retry = time.Now().Add(failedRetryDelay)
+		}
 		c.mu.Lock()
-		c.rawCache[url] = img
+		c.failed[job.key] = retry
 		c.mu.Unlock()
-	}()
+	}
 }
 
-func DownloadImage(url string) (image.Image, error) {
-	resp, err := http.Get(url)
+// Loads the image of a job into the raw cache
+func (c *GlobalImageCache) loadImage(job *downloadJob) error {
+	decode := decodeImage
+	if strings.HasPrefix(job.key, animationKeyPrefix) {
+		decode = func(data []byte) (image.Image, error) {
+			return DecodeAnimation(data)
+		}
+	}
+
+	img, err := c.fetchImage(job.ctx, job.url, decode)
+	if err != nil {
+		return err
+	}
+
+	size := rawImageSize(img)
+	if anim, ok := img.(*Animation); ok {
+		size *= int64(len(anim.Frames))
+	}
+
+	c.mu.Lock()
+	c.rawCache.Add(job.key, img, size)
+	c.mu.Unlock()
+	return nil
+}
+
+// Reports whether the PNG at url is an APNG. Until the start of the file has
+// been checked in the background it reports false, so the still is shown
+func (c *GlobalImageCache) IsAnimatedPNG(url string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.enabledLocked() {
+		return false
+	}
+	if animated, ok := c.apngCache.Get(url); ok {
+		return animated
+	}
+	key := probeKeyPrefix + url
+	c.wanted[key] = struct{}{}
+	c.loadAsyncLocked(key, url)
+	return false
+}
+
+// Fetches the start of the PNG of a job to find whether it is animated
+func (c *GlobalImageCache) probeAPNG(job *downloadJob) error {
+	req, err := http.NewRequestWithContext(job.ctx, http.MethodGet, job.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", pngProbeBytes-1))
+
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return statusError(resp.StatusCode)
+	}
+
+	// Servers that ignore the range send the whole file, of which only the
+	// start is read
+	data, err := io.ReadAll(io.LimitReader(resp.Body, pngProbeBytes))
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.apngCache.Add(job.url, isAPNG(data), int64(len(job.url)))
+	c.mu.Unlock()
+	return nil
+}
+
+// permanentError is a download failure that trying again would not fix, such
+// as a missing image or one that cannot be decoded
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Returns the error for a response that failed. Client errors are permanent,
+// while the server may recover from its own
+func statusError(code int) error {
+	err := fmt.Errorf("failed to get image: bad status code %d", code)
+	if code >= 400 && code < 500 {
+		return permanentError{err}
+	}
+	return err
+}
+
+// Loads an image from the disk cache, revalidating it with the server once it
+// is no longer fresh, or downloads it when it is not cached or has changed
+func (c *GlobalImageCache) fetchImage(ctx context.Context, url string, decode func([]byte) (image.Image, error)) (image.Image, error) {
+	decodeData := func(data []byte) (image.Image, error) {
+		img, err := decode(data)
+		if err != nil {
+			return nil, permanentError{err}
+		}
+		return img, nil
+	}
+
+	entry, cached, hasCached := c.disk.Lookup(url)
+	if hasCached && entry.Fresh() {
+		c.diskHits.Add(1)
+		return decodeData(cached)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
+	if hasCached {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		if hasCached && ctx.Err() == nil {
+			// Offline, the cached copy is better than nothing
+			c.diskHits.Add(1)
+			return decodeData(cached)
+		}
+		return nil, err
+	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get image: bad status code %d", resp.StatusCode)
+	switch {
+	case resp.StatusCode == http.StatusNotModified && hasCached:
+		c.diskHits.Add(1)
+		c.revalidated.Add(1)
+		if err := c.disk.Refresh(entry, resp.Header); err != nil {
+			log.Printf("Error caching image %s: %v", url, err)
+		}
+		return decodeData(cached)
+	case resp.StatusCode != http.StatusOK:
+		return nil, statusError(resp.StatusCode)
 	}
 
-	data, err := io.ReadAll(resp.Body)
+	data, err := readLimited(resp, MaxImageBytes)
 	if err != nil {
 		return nil, err
 	}
+	c.downloaded.Add(1)
 
-	reader := bytes.NewReader(data)
-	img, _, err := image.Decode(reader)
+	img, err := decodeData(data)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := c.disk.Store(url, data, resp.Header); err != nil {
+		log.Printf("Error caching image %s: %v", url, err)
+	}
+
 	return img, nil
 }
+
+// Decodes an image, checking its dimensions from the header first so huge
+// images are rejected before allocating their pixels
+func decodeImage(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MaxImagePixels {
+		return nil, fmt.Errorf("image too large: %dx%d pixels", cfg.Width, cfg.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// AnimatedImage is an animation scaled for the terminal, one image per frame.
+// It draws the frame last selected with SetFrame
+type AnimatedImage struct {
+	frames []vaxis.Image
+	delays []time.Duration
+	frame  int
+}
+
+func (a *AnimatedImage) Draw(win vaxis.Window) {
+	a.frames[a.frame].Draw(win)
+}
+
+func (a *AnimatedImage) Destroy() {
+	for _, frame := range a.frames {
+		frame.Destroy()
+	}
+}
+
+func (a *AnimatedImage) Resize(w int, h int) {
+	for _, frame := range a.frames {
+		frame.Resize(w, h)
+	}
+}
+
+func (a *AnimatedImage) CellSize() (int, int) {
+	return a.frames[0].CellSize()
+}
+
+// Returns the number of frames
+func (a *AnimatedImage) Len() int {
+	return len(a.frames)
+}
+
+// Returns how long frame i stays on screen
+func (a *AnimatedImage) Delay(i int) time.Duration {
+	return a.delays[i]
+}
+
+func (a *AnimatedImage) SetFrame(i int) {
+	a.frame = i % len(a.frames)
+}
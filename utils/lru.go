@@ -0,0 +1,85 @@
+package utils
+
+import "container/list"
+
+type lruEntry[V any] struct {
+	key   string
+	value V
+	size  int64
+}
+
+// lruCache is a size bounded least recently used cache. It is not safe for
+// concurrent use, callers guard it with their own lock
+type lruCache[V any] struct {
+	limit   int64
+	size    int64
+	order   *list.List
+	items   map[string]*list.Element
+	onEvict func(key string, value V)
+}
+
+func newLRUCache[V any](limit int64, onEvict func(key string, value V)) *lruCache[V] {
+	return &lruCache[V]{
+		limit:   limit,
+		order:   list.New(),
+		items:   make(map[string]*list.Element),
+		onEvict: onEvict,
+	}
+}
+
+// Returns the value for key and marks it as the most recently used
+func (c *lruCache[V]) Get(key string) (V, bool) {
+	if elem, ok := c.items[key]; ok {
+		c.order.MoveToFront(elem)
+		return elem.Value.(*lruEntry[V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Reports whether key is cached without changing its recency
+func (c *lruCache[V]) Contains(key string) bool {
+	_, ok := c.items[key]
+	return ok
+}
+
+// Adds a value of the given size in bytes, evicting the least recently used
+// entries until the cache fits its limit again. The new entry itself is never
+// evicted, so a single value larger than the limit is still kept. A value
+// already cached under key is replaced and evicted
+func (c *lruCache[V]) Add(key string, value V, size int64) {
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem, true)
+	}
+	elem := c.order.PushFront(&lruEntry[V]{key: key, value: value, size: size})
+	c.items[key] = elem
+	c.size += size
+
+	for c.size > c.limit && c.order.Len() > 1 {
+		c.removeElement(c.order.Back(), true)
+	}
+}
+
+func (c *lruCache[V]) Remove(key string) {
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem, true)
+	}
+}
+
+func (c *lruCache[V]) removeElement(elem *list.Element, evict bool) {
+	entry := elem.Value.(*lruEntry[V])
+	c.order.Remove(elem)
+	delete(c.items, entry.key)
+	c.size -= entry.size
+	if evict && c.onEvict != nil {
+		c.onEvict(entry.key, entry.value)
+	}
+}
+
+func (c *lruCache[V]) Size() int64 {
+	return c.size
+}
+
+func (c *lruCache[V]) Len() int {
+	return c.order.Len()
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLRUCache(t *testing.T) {
+	var evicted []string
+	c := newLRUCache(10, func(key string, value int) {
+		evicted = append(evicted, key)
+	})
+
+	c.Add("a", 1, 4)
+	c.Add("b", 2, 4)
+	c.Get("a")
+	c.Add("c", 3, 4)
+	if want := []string{"b"}; !slices.Equal(evicted, want) {
+		t.Errorf("evicted %v, want %v", evicted, want)
+	}
+
+	c.Add("a", 4, 2)
+	if want := []string{"b", "a"}; !slices.Equal(evicted, want) {
+		t.Errorf("after replacing, evicted %v, want %v", evicted, want)
+	}
+	if v, ok := c.Get("a"); !ok || v != 4 {
+		t.Errorf("Get(a) = %d, %v, want 4, true", v, ok)
+	}
+	if c.Size() != 6 || c.Len() != 2 {
+		t.Errorf("size %d and len %d, want 6 and 2", c.Size(), c.Len())
+	}
+
+	c.Add("big", 5, 20)
+	if c.Len() != 1 || !c.Contains("big") {
+		t.Errorf("a value over the limit should be kept alone, len %d", c.Len())
+	}
+
+	c.Remove("big")
+	if c.Len() != 0 || c.Size() != 0 || evicted[len(evicted)-1] != "big" {
+		t.Errorf("after Remove, len %d size %d evicted %v", c.Len(), c.Size(), evicted)
+	}
+}
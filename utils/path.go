@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Replaces a leading "~/" with the home directory
+func ExpandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(homeDir, rest)
+}
+
+// Returns the paths that complete text, directories ending in a separator.
+// Hidden files are only offered once their name is started with a dot
+func CompletePath(text string) []string {
+	dir, prefix := filepath.Split(text)
+	readDir := ExpandHome(dir)
+	if readDir == "" {
+		readDir = "."
+	}
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return nil
+	}
+
+	var matches []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".")) {
+			continue
+		}
+		match := dir + name
+		if info, err := os.Stat(ExpandHome(match)); err == nil && info.IsDir() {
+			match += string(filepath.Separator)
+		}
+		matches = append(matches, match)
+	}
+	slices.Sort(matches)
+	return matches
+}
+
+// Returns the longest prefix the texts share
+func CommonPrefix(texts []string) string {
+	if len(texts) == 0 {
+		return ""
+	}
+	prefix := texts[0]
+	for _, text := range texts[1:] {
+		for !strings.HasPrefix(text, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
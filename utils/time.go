@@ -50,3 +50,26 @@ func FormatTimeSince(t time.Time) string {
 		return fmt.Sprintf("%d years ago", years)
 	}
 }
+
+// FormatTimeLeft returns how long until t, such as "3 hours left"
+func FormatTimeLeft(t time.Time) string {
+	diff := time.Until(t)
+
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s left", unit)
+		}
+		return fmt.Sprintf("%d %ss left", n, unit)
+	}
+
+	switch {
+	case diff < time.Minute:
+		return "less than a minute left"
+	case diff < time.Hour:
+		return plural(int(diff.Minutes()), "minute")
+	case diff < 24*time.Hour:
+		return plural(int(diff.Hours()), "hour")
+	default:
+		return plural(int(diff.Hours()/24), "day")
+	}
+}